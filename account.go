@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccountConfig 定义单个账户的配置
+type AccountConfig struct {
+	Name          string      `yaml:"name"`
+	Cookie        string      `yaml:"cookie"`
+	Username      string      `yaml:"username"`       // 用户名，与 password 一起配置时可自动登录
+	Password      string      `yaml:"password"`       // 密码
+	QuestionID    int         `yaml:"question_id"`    // 安全提问编号 (1-7)，未设置安全提问时为 0
+	Answer        string      `yaml:"answer"`         // 安全提问的答案
+	Proxy         string      `yaml:"proxy"`          // 账户使用的代理，为空时使用全局代理，为 direct 时不使用代理
+	HeaderProfile string      `yaml:"header_profile"` // 账户使用的请求头配置名称，为空时使用全局配置
+	Push          PushConfigs `yaml:"push"`           // 账户专属的推送目标，为空时使用全局推送目标
+}
+
+// hasCredentials 返回是否配置了用于自动登录的用户名与密码
+func (c *AccountConfig) hasCredentials() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// account 定义运行时的账户
+type account struct {
+	Name        string
+	session     *forumSession
+	credentials *AccountConfig // 配置了用户名与密码时用于自动登录，否则为 nil
+	notifiers   Notifiers
+	summary     dailySummary
+	status      accountStatus
+	loggedOut   atomic.Bool // cookie 已失效，不再调度该账户的任务
+
+	loginMu      sync.Mutex
+	lastLogin    time.Time // 最近一次尝试自动登录的时间
+	lastLoginErr error     // 最近一次自动登录的结果
+
+	workFailures int // 连续打工失败的次数，只在该账户的打工任务中访问
+}
+
+// newAccounts 根据配置创建账户列表
+func newAccounts(config *Config) ([]*account, error) {
+	globalNotifiers, err := buildNotifiers(config.Push)
+	if err != nil {
+		return nil, fmt.Errorf("创建推送渠道失败: %w", err)
+	}
+
+	accounts := make([]*account, 0, len(config.Account))
+	for _, accountConfig := range config.Account {
+		notifiers := globalNotifiers
+		if len(accountConfig.Push) > 0 {
+			notifiers, err = buildNotifiers(accountConfig.Push)
+			if err != nil {
+				return nil, fmt.Errorf("[%s] 创建推送渠道失败: %w", accountConfig.Name, err)
+			}
+		}
+
+		proxy := accountConfig.Proxy
+		if proxy == "" {
+			proxy = config.Proxy
+		}
+		timeouts := config.Timeout.withDefaults()
+		client, err := newHTTPClient(proxy, timeouts.Dial)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] 创建 HTTP 客户端失败: %w", accountConfig.Name, err)
+		}
+
+		profile := accountConfig.HeaderProfile
+		if profile == "" {
+			profile = config.HeaderProfile
+		}
+		headers, err := resolveHeaderProfile(config.HeaderProfiles, profile)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", accountConfig.Name, err)
+		}
+
+		acc := &account{
+			Name: accountConfig.Name,
+			session: &forumSession{
+				client:         client,
+				jar:            newAccountCookieJar(accountConfig.Name, accountConfig.Cookie),
+				headers:        headers,
+				timeout:        timeouts.Request,
+				limiter:        rateLimit.Account.limiter(),
+				checkInLimiter: rateLimit.CheckIn.limiter(),
+			},
+			notifiers: notifiers,
+		}
+		if accountConfig.hasCredentials() {
+			acc.credentials = &accountConfig
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+// newAccountCookieJar 创建账户的 cookie 集合，配置的 cookie 未变化时使用状态存储中保存的 cookie，
+// 之后论坛返回的 Set-Cookie 会记录到状态存储中。所有镜像的主机预先加入集合，
+// 使论坛返回的带有 Domain 属性的 cookie 能同时更新同一域名下的其他镜像。
+func newAccountCookieJar(name, cookie string) *cookieJar {
+	jar := newCookieJar(cookie)
+	if hosts, ok := stateStore.Cookies(name, cookie); ok {
+		jar = newCookieJarFromHosts(cookie, hosts)
+	}
+	for _, mirror := range forumMirrors {
+		if u, err := url.Parse(mirror); err == nil {
+			jar.addHost(u.Host)
+		}
+	}
+	jar.onChange = func(hosts map[string]map[string]string) {
+		stateStore.SetCookies(name, cookie, hosts)
+	}
+	return jar
+}
+
+// logger 返回带有账户与任务字段的日志记录器
+func (acc *account) logger(task string) *slog.Logger {
+	return slog.With("account", acc.Name, "task", task)
+}
+
+// push 推送账户相关的消息，消息前会加上账户名称
+func (acc *account) push(event EventType, data string) {
+	acc.notifiers.Push(event, fmt.Sprintf("[%s] %s", acc.Name, data))
+}
+
+// active 返回账户是否可以继续执行任务
+func (acc *account) active() bool {
+	return !acc.loggedOut.Load()
+}
+
+// handleLoginError 检查任务错误是否为 ErrNotLoggedIn，是则停止调度该账户的任务并推送 cookie 失效通知
+func (acc *account) handleLoginError(err error) bool {
+	if !errors.Is(err, ErrNotLoggedIn) {
+		return false
+	}
+
+	// 只在首次检测到时通知，避免每个任务各推送一次
+	if acc.loggedOut.CompareAndSwap(false, true) {
+		acc.logger("login").Error("cookie 已失效，停止该账户的任务", "error", err)
+		acc.summary.recordError()
+		acc.status.recordError("登录", err)
+		message := "cookie 已失效，已停止该账户的任务，请更新 cookie 后重启程序"
+		if acc.credentials != nil {
+			message = fmt.Sprintf("cookie 已失效且自动登录失败，已停止该账户的任务，守护进程会定期尝试重新登录，也可以检查账户配置后重启程序: %v", err)
+		}
+		acc.push(EventCookieExpired, message)
+	}
+	return true
+}
+
+// dailySummary 定义账户每日任务汇总
+type dailySummary struct {
+	mu             sync.Mutex
+	checkIn        string // 最近一次签到结果
+	workCount      int    // 打工成功次数
+	redPacketCount int    // 抢到红包个数
+	redPacketCoins int    // 红包获得的天使币
+	errorCount     int    // 任务出错次数
+}
+
+// recordCheckIn 记录签到结果
+func (s *dailySummary) recordCheckIn(result string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkIn = result
+}
+
+// recordWork 记录一次打工成功
+func (s *dailySummary) recordWork() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workCount++
+}
+
+// recordRedPacket 记录一次抢到红包
+func (s *dailySummary) recordRedPacket(coins int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redPacketCount++
+	s.redPacketCoins += coins
+}
+
+// recordError 记录一次任务出错
+func (s *dailySummary) recordError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorCount++
+}
+
+// flush 返回汇总内容并清空记录
+func (s *dailySummary) flush() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkIn := s.checkIn
+	if checkIn == "" {
+		checkIn = "未签到"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "签到: %s\n", checkIn)
+	fmt.Fprintf(&sb, "打工成功: %d 次\n", s.workCount)
+	fmt.Fprintf(&sb, "抢到红包: %d 个，共 %d 天使币\n", s.redPacketCount, s.redPacketCoins)
+	fmt.Fprintf(&sb, "任务出错: %d 次", s.errorCount)
+
+	s.checkIn = ""
+	s.workCount = 0
+	s.redPacketCount = 0
+	s.redPacketCoins = 0
+	s.errorCount = 0
+	return sb.String()
+}
+
+// pushDailySummary 推送每日任务汇总
+func pushDailySummary(acc *account) {
+	acc.push(EventDailySummary, "任务汇总:\n"+acc.summary.flush())
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CheckInRaceConfig 定义守护进程零点抢签到的策略，时间均以估算的论坛服务器时间为准
+type CheckInRaceConfig struct {
+	StartOffset *time.Duration  `yaml:"start_offset"` // 在服务器零点之前多久开始尝试，默认为 1s，为 0 时从零点开始
+	Interval    time.Duration   `yaml:"interval"`     // 两次尝试之间的间隔，默认为 200ms
+	Parallelism int             `yaml:"parallelism"`  // 最多同时进行的签到请求数，达到上限时跳过本次尝试，默认为 3
+	Duration    time.Duration   `yaml:"duration"`     // 零点之后继续尝试的时间，超过后按 checkin_retry 重试，默认为 1m
+	StopOn      []CheckInStatus `yaml:"stop_on"`      // 得到哪些零点之后的签到结果时停止尝试，默认为 [success, already]
+}
+
+// defaultStartOffset 定义默认在服务器零点之前多久开始抢签到
+var defaultStartOffset = time.Second
+
+// defaultCheckInRace 定义默认的抢签到策略
+var defaultCheckInRace = CheckInRaceConfig{
+	StartOffset: &defaultStartOffset,
+	Interval:    200 * time.Millisecond,
+	Parallelism: 3,
+	Duration:    time.Minute,
+	StopOn:      []CheckInStatus{CheckInSuccess, CheckInAlready},
+}
+
+// checkInPrepareLead 定义开始抢签到前多久进行准备，检查登录状态、获取 formhash 并校准服务器时间
+const checkInPrepareLead = 30 * time.Second
+
+// clockSyncSamples 定义准备阶段用于校准服务器时间的请求次数，请求间隔不为整秒，使 Date 头的整秒边界落在不同位置
+const clockSyncSamples = 4
+
+// withDefaults 返回填充默认值后的抢签到策略
+func (c CheckInRaceConfig) withDefaults() CheckInRaceConfig {
+	if c.StartOffset == nil {
+		c.StartOffset = defaultCheckInRace.StartOffset
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultCheckInRace.Interval
+	}
+	if c.Parallelism <= 0 {
+		c.Parallelism = defaultCheckInRace.Parallelism
+	}
+	if c.Duration <= 0 {
+		c.Duration = defaultCheckInRace.Duration
+	}
+	if len(c.StopOn) == 0 {
+		c.StopOn = defaultCheckInRace.StopOn
+	}
+	return c
+}
+
+// check 检查抢签到策略的配置是否有效
+func (c CheckInRaceConfig) check() error {
+	if c.StartOffset != nil && *c.StartOffset < 0 {
+		return fmt.Errorf("start_offset 不能为负数")
+	}
+	if c.Parallelism < 0 {
+		return fmt.Errorf("parallelism 不能为负数")
+	}
+	for _, status := range c.StopOn {
+		if status != CheckInSuccess && status != CheckInAlready {
+			return fmt.Errorf("stop_on 只支持 success 与 already，不支持 %q", status)
+		}
+	}
+	return nil
+}
+
+// nextMidnight 返回 t 之后的下一个论坛时区零点
+func nextMidnight(t time.Time) time.Time {
+	t = t.In(forumLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, forumLocation).AddDate(0, 0, 1)
+}
+
+// prepareCheckIn 在抢签到前检查登录状态并获取 formhash，同时通过多次请求校准服务器时间
+func prepareCheckIn(ctx context.Context, acc *account) error {
+	for i := 0; i < clockSyncSamples; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(1300 * time.Millisecond):
+			}
+		}
+		if _, err := retryTask(ctx, acc, fetchFormhash); err != nil {
+			return err
+		}
+	}
+
+	offset, uncertainty, _ := forumClock.offset()
+	acc.logger("checkin").Info("已校准服务器时间", "offset", offset.Round(time.Millisecond), "uncertainty", uncertainty.Round(time.Millisecond))
+	return nil
+}
+
+// raceAttempt 定义一次抢签到尝试的结果
+type raceAttempt struct {
+	result   CheckInResult
+	err      error
+	at       time.Time // 估算的论坛处理该请求时的服务器时间
+	earliest time.Time // 论坛处理该请求时最早可能的服务器时间，考虑了服务器时间的误差范围
+}
+
+// raceAction 定义抢签到对一次尝试结果的处理方式
+type raceAction int
+
+const (
+	raceRetry  raceAction = iota // 请求失败，记录错误后继续尝试
+	raceAbort                    // cookie 已失效，继续尝试没有意义
+	raceIgnore                   // 结果属于前一天，忽略
+	raceStop                     // 得到 stop_on 中的结果，停止尝试
+	raceRecord                   // 零点之后但不在 stop_on 中的结果，记录后继续尝试
+)
+
+// judge 返回抢签到对尝试结果的处理方式。
+// 零点之前处理的请求得到的结果属于前一天；“已签到”可能是前一天的签到，请求最早可能的处理时间也在零点之后时才属于当天。
+func (c CheckInRaceConfig) judge(attempt raceAttempt, midnight time.Time) raceAction {
+	switch {
+	case attempt.err != nil:
+		if errors.Is(attempt.err, ErrNotLoggedIn) {
+			return raceAbort
+		}
+		return raceRetry
+	case attempt.at.Before(midnight):
+		return raceIgnore
+	case attempt.result.Status == CheckInAlready && attempt.earliest.Before(midnight):
+		return raceIgnore
+	case slices.Contains(c.StopOn, attempt.result.Status):
+		return raceStop
+	default:
+		return raceRecord
+	}
+}
+
+// raceCheckIn 在服务器零点 midnight 前后按策略反复尝试签到。
+// 每隔 interval 发起一次尝试，同时进行的请求不超过 parallelism，得到零点之后的 stop_on 结果时停止；
+// 零点之前得到的结果属于前一天，直接忽略；“已签到”可能是前一天的签到，只有请求最早可能的处理时间也在零点之后时才停止。
+// 超过 duration 仍未签到时返回最后一次的错误。抢签到的结果只记录一次，不记录每次尝试。
+func raceCheckIn(ctx context.Context, acc *account, race CheckInRaceConfig, midnight time.Time) (result CheckInResult, err error) {
+	logger := acc.logger("checkin")
+	defer func() { observeCheckIn(acc.Name, result, err) }()
+
+	start := forumClock.localTime(midnight.Add(-*race.StartOffset))
+	deadline := forumClock.localTime(midnight.Add(race.Duration))
+	logger.Info("等待抢签到", "start", start.In(forumLocation).Format("15:04:05.000"))
+	select {
+	case <-ctx.Done():
+		return CheckInResult{}, ctx.Err()
+	case <-time.After(time.Until(start)):
+	}
+
+	// 标记为零点签到，请求按签到限速发送
+	raceCtx, cancel := context.WithCancel(withCheckInBurst(ctx))
+	var wg sync.WaitGroup
+	defer func() {
+		cancel() // 中断进行中的请求并等待其退出
+		wg.Wait()
+	}()
+
+	// 每次尝试都会发送一个结果，进行中的请求不超过 parallelism，因此发送不会阻塞
+	attempts := make(chan raceAttempt, race.Parallelism)
+	inflight := 0
+	launch := func() {
+		inflight++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent := time.Now()
+			result, err := retryAfterLogin(raceCtx, acc, tsdmCheckIn)
+			// 论坛处理请求的时间按发送与收到响应的中点估算，最早不早于发送时间减去误差范围
+			at := forumClock.serverTime(sent.Add(time.Since(sent) / 2))
+			_, uncertainty, _ := forumClock.offset()
+			earliest := forumClock.serverTime(sent).Add(-uncertainty)
+			attempts <- raceAttempt{result: result, err: err, at: at, earliest: earliest}
+		}()
+	}
+
+	ticker := time.NewTicker(race.Interval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	var (
+		lastErr  error
+		stopped  bool           // 已得到停止尝试的结果，等待进行中的请求完成
+		stopWith CheckInResult  // 停止时的结果，进行中的请求签到成功时使用成功的结果
+		latest   *CheckInResult // 最近一次零点之后但不在 stop_on 中的结果，超时时返回
+	)
+	launch() // 立即发起第一次尝试
+	for {
+		select {
+		case <-ctx.Done():
+			return CheckInResult{}, ctx.Err()
+
+		case <-ticker.C:
+			if stopped {
+				continue
+			}
+			if inflight >= race.Parallelism {
+				continue // 进行中的请求已达上限，跳过本次尝试，避免请求堆积
+			}
+			launch()
+
+		case <-timeout.C:
+			if stopped {
+				return stopWith, nil
+			}
+			if latest != nil {
+				return *latest, nil
+			}
+			if lastErr == nil {
+				lastErr = errors.New("抢签到超时")
+			}
+			return CheckInResult{}, lastErr
+
+		case attempt := <-attempts:
+			inflight--
+			switch race.judge(attempt, midnight) {
+			case raceAbort:
+				return CheckInResult{}, attempt.err
+			case raceRetry:
+				logger.Debug("抢签到失败", "error", attempt.err)
+				lastErr = attempt.err
+			case raceIgnore:
+				logger.Debug("零点之前或无法确定是否属于今天的签到结果，忽略", "status", attempt.result.Status,
+					"earliest", attempt.earliest.In(forumLocation).Format("15:04:05.000"))
+			case raceStop:
+				// 同时进行的请求可能已经签到成功，优先使用签到成功的结果
+				if !stopped || attempt.result.Status == CheckInSuccess {
+					stopWith = attempt.result
+				}
+				stopped = true
+			case raceRecord:
+				latest = &attempt.result
+			}
+			if stopped && (inflight == 0 || stopWith.Status == CheckInSuccess) {
+				return stopWith, nil
+			}
+		}
+	}
+}
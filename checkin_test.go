@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckInRaceJudge(t *testing.T) {
+	race := defaultCheckInRace
+	midnight := time.Date(2026, 10, 17, 0, 0, 0, 0, forumLocation)
+	before := midnight.Add(-100 * time.Millisecond)
+	after := midnight.Add(100 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		attempt raceAttempt
+		want    raceAction
+	}{
+		{"请求失败", raceAttempt{err: errors.New("服务器错误"), at: after, earliest: after}, raceRetry},
+		{"cookie 失效", raceAttempt{err: ErrNotLoggedIn, at: before, earliest: before}, raceAbort},
+		{"零点之前签到成功", raceAttempt{result: CheckInResult{Status: CheckInSuccess}, at: before, earliest: before}, raceIgnore},
+		{"零点之前已签到", raceAttempt{result: CheckInResult{Status: CheckInAlready}, at: before, earliest: before}, raceIgnore},
+		{"零点之后签到成功", raceAttempt{result: CheckInResult{Status: CheckInSuccess}, at: after, earliest: after}, raceStop},
+		{"零点之后已签到", raceAttempt{result: CheckInResult{Status: CheckInAlready}, at: after, earliest: after}, raceStop},
+		{"可能在零点之前处理的已签到", raceAttempt{result: CheckInResult{Status: CheckInAlready}, at: after, earliest: before}, raceIgnore},
+		{"可能在零点之前处理的签到成功", raceAttempt{result: CheckInResult{Status: CheckInSuccess}, at: after, earliest: before}, raceStop},
+		{"零点正好处理", raceAttempt{result: CheckInResult{Status: CheckInSuccess}, at: midnight, earliest: midnight}, raceStop},
+	}
+	for _, tt := range tests {
+		if got := race.judge(tt.attempt, midnight); got != tt.want {
+			t.Errorf("%s: judge() = %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 已签到不在 stop_on 中时记录结果后继续尝试
+	race.StopOn = []CheckInStatus{CheckInSuccess}
+	attempt := raceAttempt{result: CheckInResult{Status: CheckInAlready}, at: after, earliest: after}
+	if got := race.judge(attempt, midnight); got != raceRecord {
+		t.Errorf("stop_on 为 [success] 时 judge() = %v，应为 %v", got, raceRecord)
+	}
+}
+
+func TestCheckInRaceWithDefaults(t *testing.T) {
+	zero := time.Duration(0)
+	tests := []struct {
+		name   string
+		config CheckInRaceConfig
+		want   time.Duration
+	}{
+		{"未设置", CheckInRaceConfig{}, time.Second},
+		{"start_offset 为 0", CheckInRaceConfig{StartOffset: &zero}, 0},
+	}
+	for _, tt := range tests {
+		got := tt.config.withDefaults()
+		if *got.StartOffset != tt.want || got.Interval != defaultCheckInRace.Interval || got.Parallelism != defaultCheckInRace.Parallelism {
+			t.Errorf("%s: withDefaults() = %+v (start_offset %v)", tt.name, got, *got.StartOffset)
+		}
+	}
+}
+
+func TestCheckInRaceCheck(t *testing.T) {
+	negative := -time.Second
+	tests := []struct {
+		config CheckInRaceConfig
+		valid  bool
+	}{
+		{CheckInRaceConfig{}, true},
+		{CheckInRaceConfig{StartOffset: &negative}, false},
+		{CheckInRaceConfig{Parallelism: -1}, false},
+		{CheckInRaceConfig{StopOn: []CheckInStatus{CheckInAlready}}, true},
+		{CheckInRaceConfig{StopOn: []CheckInStatus{"failure"}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.check(); (err == nil) != tt.valid {
+			t.Errorf("check(%+v) = %v", tt.config, err)
+		}
+	}
+}
+
+func TestNextMidnight(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 16, 23, 59, 59, 0, forumLocation), time.Date(2026, 10, 17, 0, 0, 0, 0, forumLocation)},
+		{time.Date(2026, 10, 17, 0, 0, 0, 0, forumLocation), time.Date(2026, 10, 18, 0, 0, 0, 0, forumLocation)},
+		{time.Date(2026, 10, 16, 16, 30, 0, 0, time.UTC), time.Date(2026, 10, 18, 0, 0, 0, 0, forumLocation)}, // UTC 16:30 为论坛时间 00:30
+	}
+	for _, tt := range tests {
+		if got := nextMidnight(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextMidnight(%v) = %v，应为 %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// cliOptions 定义子命令的运行参数
+type cliOptions struct {
+	config     *Config
+	configPath string
+	accounts   []string // --account 指定的账户，为空时表示所有账户
+	period     string   // --period 指定的 report 命令统计周期
+}
+
+// cliCommand 定义子命令
+type cliCommand struct {
+	name        string
+	description string
+	run         func(opts *cliOptions) error
+}
+
+// cliCommands 定义所有子命令，按帮助信息中的顺序排列
+var cliCommands = []cliCommand{
+	{"checkin", "执行一次签到", func(opts *cliOptions) error {
+		return runTasks(opts, runCheckIn)
+	}},
+	{"work", "执行一次打工", func(opts *cliOptions) error {
+		return runTasks(opts, func(ctx context.Context, acc *account) { runWork(ctx, acc) })
+	}},
+	{"redpacket", "检查一次红包", func(opts *cliOptions) error {
+		return runTasks(opts, checkPosts)
+	}},
+	{"score", "查询天使币数量", printScores},
+	{"report", "按日、周或月汇总红包与打工收入，使用 --period 指定统计周期", printReport},
+	{"run", "在前台以守护进程模式运行，适用于 systemd、Docker 等进程管理器", runForeground},
+	{"validate", "检查配置文件以及每个账户的 cookie 是否有效", validateConfig},
+	{"start", "在后台启动守护进程", func(opts *cliOptions) error {
+		return startDaemon(opts.config, opts.configPath, opts.accounts)
+	}},
+	{"stop", "停止守护进程", func(opts *cliOptions) error {
+		return stopDaemon(opts.config)
+	}},
+	{"status", "查看守护进程状态", func(opts *cliOptions) error {
+		return daemonStatus(opts.config)
+	}},
+	{"restart", "重启守护进程", func(opts *cliOptions) error {
+		if err := stopDaemon(opts.config); err != nil && !errors.Is(err, errNotRunning) {
+			return err
+		}
+		return startDaemon(opts.config, opts.configPath, opts.accounts)
+	}},
+}
+
+// findCommand 根据名称查找子命令
+func findCommand(name string) (cliCommand, bool) {
+	for _, command := range cliCommands {
+		if command.name == name {
+			return command, true
+		}
+	}
+	return cliCommand{}, false
+}
+
+// usage 输出命令行帮助
+func usage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintf(output, "用法: %s [-c 配置文件] [-d [-f]] [命令] [--account 账户名称]... [--period day|week|month]\n\n命令:\n", os.Args[0])
+	for _, command := range cliCommands {
+		fmt.Fprintf(output, "  %-10s %s\n", command.name, command.description)
+	}
+	fmt.Fprintln(output, "\n不指定命令时对所有账户执行一次签到、打工和抢红包任务后退出。\n\n参数:")
+	flag.PrintDefaults()
+}
+
+// accountFlag 定义可重复指定的 --account 参数
+type accountFlag []string
+
+func (f *accountFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *accountFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// selectAccounts 只保留配置中指定名称的账户，names 为空时保留所有账户
+func selectAccounts(config *Config, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(config.Account, func(a AccountConfig) bool { return a.Name == name }) {
+			return fmt.Errorf("配置文件中不存在账户: %s", name)
+		}
+	}
+	config.Account = slices.DeleteFunc(config.Account, func(a AccountConfig) bool {
+		return !slices.Contains(names, a.Name)
+	})
+	return nil
+}
+
+// signalContext 返回收到 SIGINT 或 SIGTERM 时取消的 context，用于中断正在执行的任务
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// runTasks 初始化后对选中的账户依次执行一次指定的任务
+func runTasks(opts *cliOptions, tasks ...func(ctx context.Context, acc *account)) error {
+	accounts, logCloser, err := setup(opts.config)
+	if err != nil {
+		return err
+	}
+	defer logCloser.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	runOnce(ctx, accounts, tasks...)
+	return nil
+}
+
+// runForeground 在当前进程中运行守护进程
+func runForeground(opts *cliOptions) error {
+	accounts, logCloser, err := setup(opts.config)
+	if err != nil {
+		return err
+	}
+	defer logCloser.Close()
+
+	return runDaemon(opts.config, accounts)
+}
+
+// printScores 查询并输出选中账户的天使币数量
+func printScores(opts *cliOptions) error {
+	accounts, logCloser, err := setup(opts.config)
+	if err != nil {
+		return err
+	}
+	defer logCloser.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	var failed int
+	for _, acc := range accounts {
+		score, err := retryTask(ctx, acc, getScore)
+		if err != nil {
+			acc.logger("score").Error("获取天使币失败", "error", err)
+			failed++
+			continue
+		}
+		fmt.Printf("[%s] 天使币: %s\n", acc.Name, score)
+	}
+	stateStore.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d 个账户获取天使币失败", failed)
+	}
+	return nil
+}
+
+// validateConfig 检查配置文件中的账户与推送配置，并登录论坛检查每个账户的 cookie 是否有效
+func validateConfig(opts *cliOptions) error {
+	config := opts.config
+	if len(config.Account) == 0 {
+		return errors.New("配置文件中没有账户")
+	}
+
+	var problems []string
+	seen := make(map[string]bool)
+	for i, accountConfig := range config.Account {
+		switch {
+		case accountConfig.Name == "":
+			problems = append(problems, fmt.Sprintf("第 %d 个账户没有名称", i+1))
+		case seen[accountConfig.Name]:
+			problems = append(problems, fmt.Sprintf("账户名称重复: %s", accountConfig.Name))
+		}
+		seen[accountConfig.Name] = true
+
+		if strings.TrimSpace(accountConfig.Cookie) == "" && !accountConfig.hasCredentials() {
+			problems = append(problems, fmt.Sprintf("[%s] 没有配置 cookie 或用户名与密码", accountConfig.Name))
+		}
+		if accountConfig.QuestionID < 0 || accountConfig.QuestionID > 7 {
+			problems = append(problems, fmt.Sprintf("[%s] 安全提问编号应为 0-7", accountConfig.Name))
+		}
+	}
+
+	if err := config.Retry.check(); err != nil {
+		problems = append(problems, "retry: "+err.Error())
+	}
+	if err := config.CheckInRetry.check(); err != nil {
+		problems = append(problems, "checkin_retry: "+err.Error())
+	}
+	if err := config.CheckInRace.check(); err != nil {
+		problems = append(problems, "checkin_race: "+err.Error())
+	}
+	if err := config.RedPacket.check(); err != nil {
+		problems = append(problems, "redpacket: "+err.Error())
+	}
+
+	accounts, err := newAccounts(config)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("配置文件有误:\n%s", strings.Join(problems, "\n"))
+	}
+	fmt.Printf("配置文件有效，共 %d 个账户\n", len(config.Account))
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	var invalid int
+	for _, acc := range accounts {
+		uid, err := retryTask(ctx, acc, checkLogin)
+		if err != nil {
+			fmt.Printf("[%s] cookie 无效: %v\n", acc.Name, err)
+			invalid++
+			continue
+		}
+		fmt.Printf("[%s] cookie 有效，uid: %s\n", acc.Name, uid)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d 个账户的 cookie 无效", invalid)
+	}
+	return nil
+}
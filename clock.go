@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSample 定义一次请求得到的服务器时间样本
+type clockSample struct {
+	sent     time.Time // 发送请求的本地时间
+	received time.Time // 收到响应的本地时间
+	date     time.Time // 响应的 Date 头，精确到秒
+}
+
+// serverClock 根据论坛响应的 Date 头估算服务器时钟与本地时钟的偏差。
+// Date 头只精确到秒，服务器生成响应时的时间在 [date, date+1s) 内，且发生在请求发送与收到响应之间，
+// 因此每个样本都给出偏差的一个区间，取多个样本区间的交集可以得到更精确的估计。
+type serverClock struct {
+	mu        sync.Mutex
+	samples   []clockSample
+	logged    time.Duration // 最近一次输出到日志的偏差
+	hasLogged bool
+}
+
+// ClockStatus 定义论坛服务器时钟的估算结果，用于状态页展示
+type ClockStatus struct {
+	Offset      time.Duration `json:"offset"`      // 服务器时间减去本地时间
+	Uncertainty time.Duration `json:"uncertainty"` // 偏差的误差范围
+	Samples     int           `json:"samples"`     // 参与估算的样本数
+}
+
+// maxClockSamples 定义保留的样本数量
+const maxClockSamples = 16
+
+// clockSampleTTL 定义样本的有效期，过期的样本不再参与估算
+const clockSampleTTL = time.Hour
+
+// clockLogThreshold 定义偏差变化超过该值时重新输出日志
+const clockLogThreshold = 500 * time.Millisecond
+
+// clockWarnOffset 定义偏差超过该值时输出警告，提示检查本地时钟
+const clockWarnOffset = 5 * time.Second
+
+// forumClock 定义论坛服务器时钟
+var forumClock = &serverClock{}
+
+// observe 根据响应的 Date 头记录一个样本
+func (c *serverClock) observe(sent, received time.Time, date string) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.samples = append(c.samples, clockSample{sent: sent, received: received, date: serverTime})
+	if len(c.samples) > maxClockSamples {
+		c.samples = c.samples[len(c.samples)-maxClockSamples:]
+	}
+
+	// 首次得到估计或偏差明显变化时输出日志
+	status, _ := c.estimate()
+	changed := !c.hasLogged || (status.Offset-c.logged).Abs() > clockLogThreshold
+	if changed {
+		c.logged, c.hasLogged = status.Offset, true
+	}
+	c.mu.Unlock()
+
+	observeClock(status)
+	if !changed {
+		return
+	}
+	logger := slog.With("offset", status.Offset.Round(time.Millisecond), "uncertainty", status.Uncertainty.Round(time.Millisecond))
+	if status.Offset.Abs() > clockWarnOffset {
+		logger.Warn("本地时钟与论坛服务器相差较大，已按服务器时间调度任务，建议检查本地时钟")
+	} else {
+		logger.Info("论坛服务器时间偏差")
+	}
+}
+
+// status 返回服务器时钟的估算结果，没有样本时返回 nil
+func (c *serverClock) status() *ClockStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.estimate()
+	if !ok {
+		return nil
+	}
+	return &status
+}
+
+// offset 返回服务器时间减去本地时间的偏差及其误差范围，没有样本时 ok 为 false
+func (c *serverClock) offset() (offset, uncertainty time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.estimate()
+	return status.Offset, status.Uncertainty, ok
+}
+
+// estimate 根据有效期内的样本估算偏差，调用方需持有锁
+func (c *serverClock) estimate() (status ClockStatus, ok bool) {
+	var lower, upper time.Duration
+	for i := len(c.samples) - 1; i >= 0; i-- {
+		sample := c.samples[i]
+		if time.Since(sample.received) > clockSampleTTL {
+			break
+		}
+
+		// 服务器时间 - 本地时间 的取值区间
+		sampleLower := sample.date.Sub(sample.received)
+		sampleUpper := sample.date.Add(time.Second).Sub(sample.sent)
+		if !ok {
+			lower, upper, ok = sampleLower, sampleUpper, true
+			status.Samples = 1
+			continue
+		}
+
+		newLower, newUpper := max(lower, sampleLower), min(upper, sampleUpper)
+		if newLower > newUpper {
+			break // 与较新的样本矛盾，可能是时钟被调整过，只使用较新的样本
+		}
+		lower, upper = newLower, newUpper
+		status.Samples++
+	}
+	if !ok {
+		return ClockStatus{}, false
+	}
+	status.Offset = (lower + upper) / 2
+	status.Uncertainty = (upper - lower) / 2
+	return status, true
+}
+
+// now 返回估算的服务器当前时间，没有样本时返回本地时间
+func (c *serverClock) now() time.Time {
+	return c.serverTime(time.Now())
+}
+
+// serverTime 将本地时间转换为对应的服务器时间
+func (c *serverClock) serverTime(localTime time.Time) time.Time {
+	offset, _, _ := c.offset()
+	return localTime.Add(offset)
+}
+
+// localTime 将服务器时间转换为对应的本地时间
+func (c *serverClock) localTime(serverTime time.Time) time.Time {
+	offset, _, _ := c.offset()
+	return serverTime.Add(-offset)
+}
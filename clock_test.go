@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// clockSamples 模拟服务器时钟比本地时钟快 offset 时，从 start 开始每隔 interval 发送的请求得到的样本，
+// 每个请求的往返时间为 rtt，服务器在往返的中点生成响应
+func clockSamples(start time.Time, offset, interval, rtt time.Duration, count int) []clockSample {
+	samples := make([]clockSample, 0, count)
+	for i := range count {
+		sent := start.Add(time.Duration(i) * interval)
+		samples = append(samples, clockSample{
+			sent:     sent,
+			received: sent.Add(rtt),
+			date:     sent.Add(rtt / 2).Add(offset).Truncate(time.Second),
+		})
+	}
+	return samples
+}
+
+func TestServerClockEstimate(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name           string
+		samples        []clockSample
+		offset         time.Duration // 实际的偏差
+		maxUncertainty time.Duration
+		wantSamples    int
+	}{
+		{"单个样本", clockSamples(start, 3300*time.Millisecond, 0, 100*time.Millisecond, 1), 3300 * time.Millisecond, 550 * time.Millisecond, 1},
+		{"多个样本", clockSamples(start, 3300*time.Millisecond, 1300*time.Millisecond, 100*time.Millisecond, 4), 3300 * time.Millisecond, 250 * time.Millisecond, 4},
+		{"本地时钟较快", clockSamples(start, -2700*time.Millisecond, 1300*time.Millisecond, 100*time.Millisecond, 4), -2700 * time.Millisecond, 250 * time.Millisecond, 4},
+		{
+			"时钟调整后只使用较新的样本",
+			append(clockSamples(start.Add(-10*time.Second), 30*time.Second, 1300*time.Millisecond, 100*time.Millisecond, 2),
+				clockSamples(start, 300*time.Millisecond, 1300*time.Millisecond, 100*time.Millisecond, 3)...),
+			300 * time.Millisecond, 550 * time.Millisecond, 3,
+		},
+		{
+			"忽略过期的样本",
+			append(clockSamples(start.Add(-2*clockSampleTTL), 30*time.Second, time.Second, 100*time.Millisecond, 2),
+				clockSamples(start, 300*time.Millisecond, 1300*time.Millisecond, 100*time.Millisecond, 2)...),
+			300 * time.Millisecond, 550 * time.Millisecond, 2,
+		},
+	}
+	for _, tt := range tests {
+		clock := &serverClock{samples: tt.samples}
+		status, ok := clock.estimate()
+		if !ok {
+			t.Errorf("%s: 没有得到估算结果", tt.name)
+			continue
+		}
+		if (status.Offset-tt.offset).Abs() > status.Uncertainty || status.Uncertainty > tt.maxUncertainty || status.Samples != tt.wantSamples {
+			t.Errorf("%s: 估算结果为 %+v，实际偏差为 %v", tt.name, status, tt.offset)
+		}
+	}
+}
+
+func TestServerClockWithoutSamples(t *testing.T) {
+	clock := &serverClock{}
+	if _, _, ok := clock.offset(); ok || clock.status() != nil {
+		t.Error("没有样本时不应得到估算结果")
+	}
+	local := time.Now()
+	if !clock.serverTime(local).Equal(local) {
+		t.Error("没有样本时应使用本地时间")
+	}
+}
+
+func TestServerClockConversion(t *testing.T) {
+	clock := &serverClock{samples: clockSamples(time.Now().Add(-time.Minute), 5*time.Second, 1300*time.Millisecond, 100*time.Millisecond, 4)}
+	offset, _, _ := clock.offset()
+
+	midnight := time.Date(2026, 10, 17, 0, 0, 0, 0, forumLocation)
+	local := clock.localTime(midnight)
+	if got := midnight.Sub(local); got != offset {
+		t.Errorf("服务器零点对应的本地时间相差 %v，应为 %v", got, offset)
+	}
+	if !clock.serverTime(local).Equal(midnight) {
+		t.Errorf("serverTime(localTime(t)) = %v，应为 %v", clock.serverTime(local), midnight)
+	}
+}
+
+func TestServerClockObserve(t *testing.T) {
+	clock := &serverClock{}
+	sent := time.Now()
+	clock.observe(sent, sent.Add(100*time.Millisecond), "invalid")
+	if clock.status() != nil {
+		t.Error("无法解析的 Date 头不应记录为样本")
+	}
+
+	for i := range maxClockSamples + 4 {
+		sent := time.Now().Add(time.Duration(i-maxClockSamples-4) * time.Second)
+		clock.observe(sent, sent.Add(100*time.Millisecond), sent.UTC().Format(http.TimeFormat))
+	}
+	if len(clock.samples) != maxClockSamples {
+		t.Errorf("保留了 %d 个样本，应为 %d", len(clock.samples), maxClockSamples)
+	}
+}
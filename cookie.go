@@ -0,0 +1,206 @@
+package main
+
+import (
+	"maps"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// cookieJar 定义账户的 cookie 集合，每个论坛镜像的主机单独保存一组 cookie，发送请求时附带该主机的 cookie，
+// 并根据响应中的 Set-Cookie 更新。配置的 cookie 作为每个主机的初始 cookie；
+// Set-Cookie 没有 Domain 属性时只更新返回该响应的主机，有 Domain 属性时更新所有匹配该域名的主机。
+// 论坛的 cookie 都设置在根路径，Path 属性会被忽略。
+type cookieJar struct {
+	mu    sync.Mutex
+	seed  map[string]string            // 配置的 cookie，首次访问某个主机时作为该主机的 cookie
+	hosts map[string]map[string]string // 每个主机的 cookie，key 为不含端口的小写主机名
+
+	// onChange 在 cookie 变化后调用，参数为所有主机的 cookie 的副本，用于持久化
+	onChange func(hosts map[string]map[string]string)
+}
+
+// newCookieJar 根据浏览器复制的 cookie 字符串 (如 "a=1; b=2") 创建 cookie 集合
+func newCookieJar(cookie string) *cookieJar {
+	return &cookieJar{seed: parseCookie(cookie), hosts: map[string]map[string]string{}}
+}
+
+// newCookieJarFromHosts 根据配置的 cookie 字符串与保存的每个主机的 cookie 创建 cookie 集合
+func newCookieJarFromHosts(cookie string, hosts map[string]map[string]string) *cookieJar {
+	return &cookieJar{seed: parseCookie(cookie), hosts: cloneHostCookies(hosts)}
+}
+
+// parseCookie 解析 cookie 字符串
+func parseCookie(cookie string) map[string]string {
+	cookies := map[string]string{}
+	for _, pair := range strings.Split(cookie, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			continue
+		}
+		cookies[name] = value
+	}
+	return cookies
+}
+
+// cloneHostCookies 返回每个主机的 cookie 的深拷贝
+func cloneHostCookies(hosts map[string]map[string]string) map[string]map[string]string {
+	cloned := make(map[string]map[string]string, len(hosts))
+	for host, cookies := range hosts {
+		cloned[host] = maps.Clone(cookies)
+	}
+	return cloned
+}
+
+// cookieHost 返回用于区分 cookie 的主机名，去掉端口并转为小写
+func cookieHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// domainMatch 返回主机是否属于 Set-Cookie 的 Domain 属性指定的域名
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// host 返回主机的 cookie，首次访问时使用配置的 cookie，调用方需持有锁
+func (j *cookieJar) host(host string) map[string]string {
+	cookies, ok := j.hosts[host]
+	if !ok {
+		cookies = maps.Clone(j.seed)
+		j.hosts[host] = cookies
+	}
+	return cookies
+}
+
+// addHost 预先为主机创建 cookie，使其他主机返回的带有 Domain 属性的 cookie 也能更新该主机
+func (j *cookieJar) addHost(host string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.host(cookieHost(host))
+}
+
+// header 返回发往 host 的请求使用的 Cookie 请求头，按名称排序
+func (j *cookieJar) header(host string) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cookies := j.host(cookieHost(host))
+	names := make([]string, 0, len(cookies))
+	for name := range cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+cookies[name])
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// has 返回任意主机是否存在名称以 suffix 结尾的 cookie，用于忽略 Discuz 的 cookie 前缀
+func (j *cookieJar) has(suffix string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, cookies := range j.hosts {
+		for name := range cookies {
+			if strings.HasSuffix(name, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reset 清空所有主机的 cookie 并使用新的 cookie 字符串作为初始 cookie
+func (j *cookieJar) reset(cookie string) {
+	j.mu.Lock()
+	j.seed = parseCookie(cookie)
+	hosts := make([]string, 0, len(j.hosts))
+	for host := range j.hosts {
+		hosts = append(hosts, host)
+	}
+	j.hosts = map[string]map[string]string{}
+	for _, host := range hosts {
+		j.host(host)
+	}
+	cookies := cloneHostCookies(j.hosts)
+	j.mu.Unlock()
+
+	j.changed(cookies)
+}
+
+// changed 通知 cookie 已变化，需在释放锁后调用
+func (j *cookieJar) changed(hosts map[string]map[string]string) {
+	if j.onChange != nil {
+		j.onChange(hosts)
+	}
+}
+
+// update 根据 host 返回的响应中的 Set-Cookie 更新集合，已过期或值为 deleted 的 cookie 会被删除，
+// Domain 属性与 host 不匹配的 cookie 会被忽略
+func (j *cookieJar) update(host string, resp *fasthttp.Response) {
+	host = cookieHost(host)
+
+	j.mu.Lock()
+	j.host(host)
+	changed := false
+	resp.Header.VisitAllCookie(func(_, setCookie []byte) {
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		if err := cookie.ParseBytes(setCookie); err != nil {
+			return
+		}
+
+		// 没有 Domain 属性时只发往返回该 cookie 的主机，否则发往所有匹配该域名的主机
+		targets := []string{host}
+		if domain := strings.ToLower(strings.TrimPrefix(string(cookie.Domain()), ".")); domain != "" {
+			if !domainMatch(host, domain) {
+				return
+			}
+			targets = targets[:0]
+			for target := range j.hosts {
+				if domainMatch(target, domain) {
+					targets = append(targets, target)
+				}
+			}
+		}
+
+		name := string(cookie.Key())
+		value := string(cookie.Value())
+		expire := cookie.Expire()
+		remove := value == "" || value == "deleted" ||
+			(expire != fasthttp.CookieExpireUnlimited && expire.Before(time.Now()))
+		for _, target := range targets {
+			cookies := j.hosts[target]
+			if remove {
+				if _, ok := cookies[name]; ok {
+					delete(cookies, name)
+					changed = true
+				}
+			} else if cookies[name] != value {
+				cookies[name] = value
+				changed = true
+			}
+		}
+	})
+
+	var hosts map[string]map[string]string
+	if changed {
+		hosts = cloneHostCookies(j.hosts)
+	}
+	j.mu.Unlock()
+
+	if changed {
+		j.changed(hosts)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// testSetCookie 让 host 返回带有 setCookies 的响应并更新 cookie 集合
+func testSetCookie(jar *cookieJar, host string, setCookies ...string) {
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	for _, setCookie := range setCookies {
+		resp.Header.Add("Set-Cookie", setCookie)
+	}
+	jar.update(host, resp)
+}
+
+func TestCookieJarHosts(t *testing.T) {
+	jar := newCookieJar("auth=seed; sid=1")
+	jar.addHost("www.tsdm39.com")
+	jar.addHost("tsdm39.com")
+	jar.addHost("www.tsdm39.net:443")
+
+	testSetCookie(jar, "www.tsdm39.com", "sid=2; path=/")
+	testSetCookie(jar, "www.tsdm39.com", "lastact=3; domain=.tsdm39.com; path=/")
+	testSetCookie(jar, "www.tsdm39.net", "auth=deleted; path=/")
+	testSetCookie(jar, "www.tsdm39.net", "evil=1; domain=.tsdm39.com; path=/") // 域名不匹配，忽略
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"www.tsdm39.com", "auth=seed; lastact=3; sid=2"},
+		{"tsdm39.com", "auth=seed; lastact=3; sid=1"},
+		{"www.tsdm39.net", "sid=1"},
+		{"WWW.TSDM39.NET:8443", "sid=1"},
+		{"new.example.com", "auth=seed; sid=1"}, // 首次访问的主机使用配置的 cookie
+	}
+	for _, tt := range tests {
+		if got := jar.header(tt.host); got != tt.want {
+			t.Errorf("header(%q) = %q，应为 %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCookieJarChanged(t *testing.T) {
+	jar := newCookieJar("sid=1")
+	var saved map[string]map[string]string
+	jar.onChange = func(hosts map[string]map[string]string) { saved = hosts }
+
+	testSetCookie(jar, "127.0.0.1:8080", "sid=1; path=/")
+	if saved != nil {
+		t.Fatal("cookie 没有变化时不应保存")
+	}
+	testSetCookie(jar, "127.0.0.1:8080", "s_gkr8_abcd_auth=x; path=/")
+	if saved["127.0.0.1"]["s_gkr8_abcd_auth"] != "x" || !jar.has("_auth") {
+		t.Errorf("保存的 cookie 为 %v", saved)
+	}
+
+	restored := newCookieJarFromHosts("sid=1", saved)
+	if got := restored.header("127.0.0.1"); got != "s_gkr8_abcd_auth=x; sid=1" {
+		t.Errorf("恢复后的 cookie 为 %q", got)
+	}
+
+	jar.reset("")
+	if jar.has("_auth") || saved["127.0.0.1"] == nil || len(saved["127.0.0.1"]) != 0 {
+		t.Errorf("清空后保存的 cookie 为 %v", saved)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// daemonEnv 定义标记后台进程的环境变量
+	daemonEnv = "TSDMTASK_DAEMON"
+	// daemonStartTimeout 定义等待后台进程启动的时间
+	daemonStartTimeout = 5 * time.Second
+	// daemonStopTimeout 定义等待守护进程退出的时间
+	daemonStopTimeout = 30 * time.Second
+)
+
+// errNotRunning 表示守护进程未在运行
+var errNotRunning = errors.New("守护进程未在运行")
+
+// isBackgroundProcess 判断当前进程是否为 start 命令启动的后台进程
+func isBackgroundProcess() bool {
+	return os.Getenv(daemonEnv) != ""
+}
+
+// pidLock 定义持有文件锁的 PID 文件，进程退出时锁自动释放
+type pidLock struct {
+	path string
+	file *os.File
+}
+
+// acquirePIDLock 锁定 PID 文件并写入当前进程的 PID，已有其他进程持有锁时返回错误
+func acquirePIDLock(path string) (*pidLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("创建 PID 文件目录失败: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开 PID 文件失败: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := readPID(path)
+			return nil, fmt.Errorf("守护进程已在运行，PID: %d", pid)
+		}
+		return nil, fmt.Errorf("锁定 PID 文件失败: %w", err)
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %w", err)
+	}
+
+	return &pidLock{path: path, file: file}, nil
+}
+
+// release 删除 PID 文件并释放锁
+func (l *pidLock) release() {
+	os.Remove(l.path)
+	l.file.Close()
+}
+
+// readPID 读取 PID 文件中的 PID
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// runningPID 返回正在运行的守护进程 PID，PID 文件未被锁定时返回 errNotRunning
+func runningPID(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, errNotRunning
+	}
+	if err != nil {
+		return 0, fmt.Errorf("打开 PID 文件失败: %w", err)
+	}
+	defer file.Close()
+
+	// 能获取到锁说明持有锁的进程已退出，PID 文件是残留的
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		return 0, errNotRunning
+	} else if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return 0, fmt.Errorf("检查 PID 文件锁失败: %w", err)
+	}
+
+	pid, err := readPID(path)
+	if err != nil {
+		return 0, fmt.Errorf("读取 PID 文件失败: %w", err)
+	}
+	return pid, nil
+}
+
+// startDaemon 在后台启动守护进程，新进程脱离终端并将输出重定向到状态目录下的 tsdmtask.out
+func startDaemon(config *Config, configPath string, accountNames []string) error {
+	pidFile := config.pidFile()
+	if pid, err := runningPID(pidFile); err == nil {
+		return fmt.Errorf("守护进程已在运行，PID: %d", pid)
+	} else if !errors.Is(err, errNotRunning) {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("无法获取程序路径: %w", err)
+	}
+	// 使用配置文件的绝对路径，避免后台进程依赖相对路径
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return fmt.Errorf("无法获取配置文件路径: %w", err)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("无法获取工作目录: %w", err)
+	}
+
+	stateDir := config.stateDir()
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return fmt.Errorf("创建状态目录失败: %w", err)
+	}
+	outPath := filepath.Join(stateDir, "tsdmtask.out")
+	output, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开输出文件失败: %w", err)
+	}
+	defer output.Close()
+
+	args := []string{"-c", configPath, "run"}
+	for _, name := range accountNames {
+		args = append(args, "--account", name)
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("无法启动后台进程: %w", err)
+	}
+	childPID := cmd.Process.Pid
+
+	// 等待后台进程锁定 PID 文件，子进程提前退出时报告失败
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(daemonStartTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("后台进程启动失败，详见 %s", outPath)
+		case <-deadline:
+			return fmt.Errorf("等待后台进程启动超时，PID: %d，详见 %s", childPID, outPath)
+		case <-ticker.C:
+			if pid, err := runningPID(pidFile); err == nil && pid == childPID {
+				fmt.Printf("后台进程已启动，PID: %d\n", pid)
+				return nil
+			}
+		}
+	}
+}
+
+// stopDaemon 向守护进程发送 SIGTERM 并等待其退出
+func stopDaemon(config *Config) error {
+	pidFile := config.pidFile()
+	pid, err := runningPID(pidFile)
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("无法停止守护进程 (PID: %d): %w", pid, err)
+	}
+
+	deadline := time.Now().Add(daemonStopTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := runningPID(pidFile); errors.Is(err, errNotRunning) {
+			fmt.Printf("守护进程已停止，PID: %d\n", pid)
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("等待守护进程 (PID: %d) 退出超时", pid)
+}
+
+// daemonStatus 输出守护进程的运行状态与各账户的任务时间
+func daemonStatus(config *Config) error {
+	pid, err := runningPID(config.pidFile())
+	if errors.Is(err, errNotRunning) {
+		fmt.Println("守护进程未在运行")
+	} else if err != nil {
+		return err
+	} else {
+		fmt.Printf("守护进程正在运行，PID: %d\n", pid)
+	}
+
+	store, err := openStateStore(config.stateDir())
+	if err != nil {
+		return err
+	}
+	for _, accountConfig := range config.Account {
+		lastCheckIn := store.LastCheckIn(accountConfig.Name)
+		if lastCheckIn == "" {
+			lastCheckIn = "-"
+		}
+		nextWork := "-"
+		if t := store.NextWork(accountConfig.Name); !t.IsZero() {
+			nextWork = t.In(forumLocation).Format(time.DateTime)
+		}
+		fmt.Printf("[%s] 最后签到: %s，下次打工: %s\n", accountConfig.Name, lastCheckIn, nextWork)
+	}
+	return nil
+}
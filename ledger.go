@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ledgerFileName 定义收入记录文件名
+const ledgerFileName = "ledger.jsonl"
+
+// LedgerType 定义收入记录的类型
+type LedgerType string
+
+const (
+	LedgerRedPacket LedgerType = "redpacket" // 抢红包
+	LedgerWork      LedgerType = "work"      // 打工
+)
+
+// ledgerError 定义请求失败时记录的状态
+const ledgerError = "error"
+
+// LedgerEntry 定义一条收入记录，每次领取红包与打工的结果各占一行
+type LedgerEntry struct {
+	Time    time.Time  `json:"time"`
+	Account string     `json:"account"`
+	Type    LedgerType `json:"type"`
+	TID     string     `json:"tid,omitempty"`   // 红包所在的帖子 ID
+	Title   string     `json:"title,omitempty"` // 红包所在的帖子标题
+	Status  string     `json:"status"`          // 红包或打工结果的状态，请求失败时为 error
+	Coins   int        `json:"coins"`           // 获得的天使币
+	Error   string     `json:"error,omitempty"` // 请求失败的原因
+}
+
+// Ledger 定义保存在本地 JSONL 文件中的收入记录，只追加不修改
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// ledger 定义全局收入记录，未调用 openLedger 时不保存
+var ledger = &Ledger{}
+
+// openLedger 返回指定目录下的收入记录，文件在第一次写入时创建
+func openLedger(dir string) *Ledger {
+	return &Ledger{path: filepath.Join(dir, ledgerFileName)}
+}
+
+// record 追加一条收入记录，写入失败时只输出日志
+func (l *Ledger) record(entry LedgerEntry) {
+	if l.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("序列化收入记录失败", "error", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		slog.Error("打开收入记录文件失败", "path", l.path, "error", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		slog.Error("写入收入记录失败", "path", l.path, "error", err)
+	}
+}
+
+// recordRedPacket 记录一次领取红包的结果，err 不为 nil 时记录为请求失败
+func (l *Ledger) recordRedPacket(name string, thread forumThread, result RedPacketResult, err error) {
+	entry := LedgerEntry{
+		Time:    time.Now(),
+		Account: name,
+		Type:    LedgerRedPacket,
+		TID:     thread.TID,
+		Title:   thread.Title,
+		Status:  string(result.Status),
+		Coins:   result.Coins,
+	}
+	if err != nil {
+		entry.Status, entry.Error = ledgerError, err.Error()
+	}
+	l.record(entry)
+}
+
+// recordWork 记录一次打工的结果，err 不为 nil 时记录为请求失败
+func (l *Ledger) recordWork(name string, result WorkResult, err error) {
+	entry := LedgerEntry{
+		Time:    time.Now(),
+		Account: name,
+		Type:    LedgerWork,
+		Status:  string(result.Status),
+		Coins:   result.Coins,
+	}
+	if err != nil {
+		entry.Status, entry.Error = ledgerError, err.Error()
+	}
+	l.record(entry)
+}
+
+// entries 读取所有收入记录，文件不存在时返回空列表，跳过无法解析的行
+func (l *Ledger) entries() ([]LedgerEntry, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取收入记录失败: %w", err)
+	}
+	defer file.Close()
+
+	var entries []LedgerEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("跳过无法解析的收入记录", "path", l.path, "line", line, "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取收入记录失败: %w", err)
+	}
+	return entries, nil
+}
+
+// reportPeriods 定义收入报告支持的统计周期，值为返回周期名称的函数，时间按论坛时区计算
+var reportPeriods = map[string]func(t time.Time) string{
+	"day": func(t time.Time) string {
+		return forumDate(t)
+	},
+	"week": func(t time.Time) string {
+		year, week := t.In(forumLocation).ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"month": func(t time.Time) string {
+		return t.In(forumLocation).Format("2006-01")
+	},
+}
+
+// reportRow 定义收入报告中一个账户在一个周期内的统计
+type reportRow struct {
+	period         string
+	redPackets     int // 抢到的红包数
+	redPacketCoins int
+	works          int // 打工成功次数
+	workCoins      int
+}
+
+// reportRows 按统计周期汇总账户抢到的红包与打工成功的收入，按周期排序
+func reportRows(entries []LedgerEntry, account string, periodName func(t time.Time) string) []*reportRow {
+	var rows []*reportRow
+	index := make(map[string]*reportRow)
+	for _, entry := range entries {
+		if entry.Account != account {
+			continue
+		}
+		var grabbed, worked bool
+		switch entry.Type {
+		case LedgerRedPacket:
+			grabbed = entry.Status == string(RedPacketGrabbed)
+		case LedgerWork:
+			worked = entry.Status == string(WorkSuccess)
+		}
+		if !grabbed && !worked {
+			continue
+		}
+
+		period := periodName(entry.Time)
+		row, ok := index[period]
+		if !ok {
+			row = &reportRow{period: period}
+			index[period] = row
+			rows = append(rows, row)
+		}
+		if grabbed {
+			row.redPackets++
+			row.redPacketCoins += entry.Coins
+		} else {
+			row.works++
+			row.workCoins += entry.Coins
+		}
+	}
+	slices.SortFunc(rows, func(a, b *reportRow) int { return cmp.Compare(a.period, b.period) })
+	return rows
+}
+
+// printReport 按统计周期汇总选中账户的红包与打工收入
+func printReport(opts *cliOptions) error {
+	periodName, ok := reportPeriods[opts.period]
+	if !ok {
+		return fmt.Errorf("不支持的统计周期: %s，可选 day、week 或 month", opts.period)
+	}
+
+	entries, err := openLedger(opts.config.stateDir()).entries()
+	if err != nil {
+		return err
+	}
+	writeReport(os.Stdout, opts.config.Account, entries, periodName)
+	return nil
+}
+
+// writeReport 将每个账户的收入报告写入 w
+func writeReport(w io.Writer, accounts []AccountConfig, entries []LedgerEntry, periodName func(t time.Time) string) {
+	for _, accountConfig := range accounts {
+		fmt.Fprintf(w, "[%s]\n", accountConfig.Name)
+		rows := reportRows(entries, accountConfig.Name, periodName)
+		if len(rows) == 0 {
+			fmt.Fprintln(w, "  暂无收入记录")
+			continue
+		}
+		for _, row := range rows {
+			fmt.Fprintf(w, "  %-10s 红包 %d 个 %d 天使币，打工 %d 次 %d 天使币，合计 %d 天使币\n",
+				row.period, row.redPackets, row.redPacketCoins, row.works, row.workCoins, row.redPacketCoins+row.workCoins)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// utc 解析 RFC 3339 格式的时间，用于构造测试数据
+func utc(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestReportPeriods(t *testing.T) {
+	tests := []struct {
+		period string
+		time   string
+		want   string
+	}{
+		{"day", "2026-10-16T15:59:59Z", "2026-10-16"},
+		{"day", "2026-10-16T16:00:00Z", "2026-10-17"}, // 论坛时间已是第二天零点
+		{"week", "2026-10-18T15:00:00Z", "2026-W42"},  // 论坛时间周日 23:00
+		{"week", "2026-10-18T16:30:00Z", "2026-W43"},  // 论坛时间周一 00:30
+		{"month", "2026-10-31T15:59:59Z", "2026-10"},
+		{"month", "2026-10-31T16:30:00Z", "2026-11"},
+	}
+	for _, tt := range tests {
+		if got := reportPeriods[tt.period](utc(t, tt.time)); got != tt.want {
+			t.Errorf("%s(%s) = %s，应为 %s", tt.period, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestReportRows(t *testing.T) {
+	entries := []LedgerEntry{
+		{Time: utc(t, "2026-10-17T02:00:00Z"), Account: "a", Type: LedgerWork, Status: string(WorkSuccess), Coins: 20},
+		{Time: utc(t, "2026-10-16T01:00:00Z"), Account: "a", Type: LedgerRedPacket, Status: string(RedPacketGrabbed), Coins: 5},
+		{Time: utc(t, "2026-10-16T17:00:00Z"), Account: "a", Type: LedgerRedPacket, Status: string(RedPacketGrabbed), Coins: 7}, // 论坛时间 10-17
+		{Time: utc(t, "2026-10-16T03:00:00Z"), Account: "a", Type: LedgerRedPacket, Status: string(RedPacketLate)},
+		{Time: utc(t, "2026-10-16T04:00:00Z"), Account: "a", Type: LedgerWork, Status: ledgerError, Error: "打工失败"},
+		{Time: utc(t, "2026-10-16T05:00:00Z"), Account: "a", Type: LedgerWork, Status: string(WorkWaiting)},
+		{Time: utc(t, "2026-10-16T06:00:00Z"), Account: "b", Type: LedgerRedPacket, Status: string(RedPacketGrabbed), Coins: 100},
+	}
+	tests := []struct {
+		period string
+		want   []reportRow
+	}{
+		{"day", []reportRow{
+			{period: "2026-10-16", redPackets: 1, redPacketCoins: 5},
+			{period: "2026-10-17", redPackets: 1, redPacketCoins: 7, works: 1, workCoins: 20},
+		}},
+		{"month", []reportRow{
+			{period: "2026-10", redPackets: 2, redPacketCoins: 12, works: 1, workCoins: 20},
+		}},
+	}
+	for _, tt := range tests {
+		rows := reportRows(entries, "a", reportPeriods[tt.period])
+		if len(rows) != len(tt.want) {
+			t.Errorf("%s: 得到 %d 行，应为 %d 行", tt.period, len(rows), len(tt.want))
+			continue
+		}
+		for i, row := range rows {
+			if *row != tt.want[i] {
+				t.Errorf("%s: 第 %d 行为 %+v，应为 %+v", tt.period, i+1, *row, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	entries := []LedgerEntry{
+		{Time: utc(t, "2026-10-16T01:00:00Z"), Account: "a", Type: LedgerRedPacket, Status: string(RedPacketGrabbed), Coins: 5},
+	}
+	var buf bytes.Buffer
+	writeReport(&buf, []AccountConfig{{Name: "a"}, {Name: "b"}}, entries, reportPeriods["day"])
+
+	want := "[a]\n  2026-10-16 红包 1 个 5 天使币，打工 0 次 0 天使币，合计 5 天使币\n[b]\n  暂无收入记录\n"
+	if buf.String() != want {
+		t.Errorf("报告为:\n%s\n应为:\n%s", buf.String(), want)
+	}
+}
+
+func TestLedgerEntries(t *testing.T) {
+	ledger := openLedger(t.TempDir())
+	if entries, err := ledger.entries(); err != nil || entries != nil {
+		t.Fatalf("文件不存在时返回 %v, %v", entries, err)
+	}
+
+	ledger.recordRedPacket("a", forumThread{TID: "1", Title: "红包"}, RedPacketResult{Status: RedPacketGrabbed, TID: "1", Coins: 5}, nil)
+	file, err := os.OpenFile(ledger.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{broken\n")
+	file.Close()
+	ledger.recordWork("a", WorkResult{}, errors.New("服务器错误"))
+
+	entries, err := ledger.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("读取到 %d 条记录，应跳过无法解析的行得到 2 条", len(entries))
+	}
+	if entries[0].Title != "红包" || entries[0].Coins != 5 || entries[1].Status != ledgerError || entries[1].Error != "服务器错误" {
+		t.Errorf("记录为 %+v", entries)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogConfig 定义日志配置
+type LogConfig struct {
+	Level      string `yaml:"level"`       // 日志级别: debug、info、warn、error，默认为 info
+	Format     string `yaml:"format"`      // 日志格式: text 或 json，默认为 text
+	File       string `yaml:"file"`        // 日志文件路径，为空时输出到标准输出，start 命令启动的后台进程默认为状态目录下的 tsdmtask.log
+	MaxSize    int    `yaml:"max_size"`    // 单个日志文件的大小上限 (MB)，默认为 10
+	MaxBackups int    `yaml:"max_backups"` // 保留的旧日志文件数，默认为 5
+}
+
+// setupLogger 根据配置创建日志记录器并设置为默认日志记录器，返回的 io.Closer 用于关闭日志文件
+func setupLogger(config LogConfig) (io.Closer, error) {
+	var level slog.Level
+	if config.Level != "" {
+		if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+			return nil, fmt.Errorf("日志级别 %q 不受支持", config.Level)
+		}
+	}
+
+	var output io.Writer = os.Stdout
+	var closer io.Closer = io.NopCloser(nil)
+	if config.File != "" {
+		writer, err := newRotateWriter(config.File, config.MaxSize, config.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		output = writer
+		closer = writer
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(output, options)
+	case "json":
+		handler = slog.NewJSONHandler(output, options)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("日志格式 %q 不受支持", config.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// rotateWriter 定义按大小轮转的日志文件，超过大小上限时将当前文件重命名为 file.1，旧文件依次后移
+type rotateWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// newRotateWriter 打开日志文件，maxSizeMB 与 maxBackups 为 0 时使用默认值
+func newRotateWriter(path string, maxSizeMB, maxBackups int) (*rotateWriter, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = 10
+	}
+	if maxBackups <= 0 {
+		maxBackups = 5
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+
+	w := &rotateWriter{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open 以追加方式打开日志文件
+func (w *rotateWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write 写入日志，写入后超过大小上限时先轮转
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate 轮转日志文件，调用方需持有锁
+func (w *rotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxBackups))
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close 关闭日志文件
+func (w *rotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
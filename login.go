@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNotLoggedIn 表示论坛返回了未登录的页面，通常是 cookie 已失效
+var ErrNotLoggedIn = errors.New("未登录，cookie 可能已失效")
+
+// discuzUIDRegex 匹配 Discuz 页面脚本中的 discuz_uid，未登录时为 0
+var discuzUIDRegex = regexp.MustCompile(`discuz_uid\s*=\s*'(\d+)'`)
+
+// notLoggedInMarkers 定义未登录时页面或 AJAX 返回内容中出现的标记
+var notLoggedInMarkers = [][]byte{
+	[]byte(`id="lsform"`), // 页头的登录表单
+	[]byte("您需要先登录才能继续本操作"),
+	[]byte("请先登录后才能继续"),
+}
+
+// checkLoginPage 检查论坛返回的内容是否为未登录状态，未登录时返回 ErrNotLoggedIn
+func checkLoginPage(page []byte) error {
+	if matches := discuzUIDRegex.FindSubmatch(page); matches != nil {
+		if string(matches[1]) == "0" {
+			return ErrNotLoggedIn
+		}
+		return nil
+	}
+
+	for _, marker := range notLoggedInMarkers {
+		if bytes.Contains(page, marker) {
+			return ErrNotLoggedIn
+		}
+	}
+	return nil
+}
+
+// checkLogin 请求论坛首页检查账户的登录状态，返回登录用户的 uid
+func checkLogin(ctx context.Context, acc *account) (string, error) {
+	respData, err := forumRequest(ctx, "GET", "/forum.php", "", nil, acc.session)
+	if err != nil {
+		return "", fmt.Errorf("获取页面内容失败: %w", err)
+	}
+
+	if err := checkLoginPage(respData); err != nil {
+		return "", err
+	}
+
+	matches := discuzUIDRegex.FindSubmatch(respData)
+	if matches == nil {
+		return "", fmt.Errorf("无法识别登录状态")
+	}
+	return string(matches[1]), nil
+}
+
+// reloginInterval 定义守护进程中自动登录被拒绝的账户再次尝试登录的间隔
+const reloginInterval = 30 * time.Minute
+
+// loginRejectedError 表示论坛拒绝了登录，如密码错误、需要验证码或没有返回登录 cookie，重新尝试没有意义
+type loginRejectedError struct {
+	err error
+}
+
+func (e *loginRejectedError) Error() string { return e.err.Error() }
+func (e *loginRejectedError) Unwrap() error { return e.err }
+
+// loginRejected 创建论坛拒绝登录的错误
+func loginRejected(format string, args ...any) error {
+	return &loginRejectedError{err: fmt.Errorf(format, args...)}
+}
+
+// isLoginRejected 返回错误是否为论坛拒绝登录，网络错误、服务器错误等临时问题不属于拒绝登录
+func isLoginRejected(err error) bool {
+	var rejected *loginRejectedError
+	return errors.As(err, &rejected)
+}
+
+// ajaxMessageRegex 匹配 Discuz AJAX 返回内容中的提示信息
+var ajaxMessageRegex = regexp.MustCompile(`(?:errorhandle_\w*|showDialog)\('([^']*)'`)
+
+// login 使用账户配置的用户名与密码执行 Discuz 的登录流程，登录成功后 cookie 保存在账户的 jar 中
+func login(ctx context.Context, acc *account) error {
+	credentials := acc.credentials
+	if credentials == nil {
+		return fmt.Errorf("未配置用户名与密码")
+	}
+
+	// 清空失效的 cookie，避免论坛按旧的登录状态处理
+	acc.session.jar.reset("")
+
+	// 获取登录表单，表单的提交地址中带有 loginhash
+	respData, err := forumRequest(ctx, "GET", "/member.php?mod=logging&action=login", "", nil, acc.session)
+	if err != nil {
+		return fmt.Errorf("获取登录页面失败: %w", err)
+	}
+
+	contentType := mimetype.Detect(respData).String()
+	reader, err := charset.NewReader(bytes.NewReader(respData), contentType)
+	if err != nil {
+		return fmt.Errorf("创建 reader 失败: %w", err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	form := doc.Find("form[name='login']").First()
+	action, exists := form.Attr("action")
+	if !exists {
+		return fmt.Errorf("登录表单不存在")
+	}
+	formhash, exists := form.Find("input[name='formhash']").Attr("value")
+	if !exists {
+		return fmt.Errorf("formhash 不存在")
+	}
+	if form.Find("input[name='seccodeverify']").Length() > 0 {
+		return loginRejected("登录需要验证码，无法自动登录")
+	}
+
+	formData := url.Values{
+		"formhash":   {formhash},
+		"referer":    {"forum.php"},
+		"loginfield": {"username"},
+		"username":   {credentials.Username},
+		"password":   {credentials.Password},
+		"questionid": {strconv.Itoa(credentials.QuestionID)},
+		"answer":     {credentials.Answer},
+		"cookietime": {"2592000"}, // 保持登录 30 天
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+		"Origin":       "/",
+		"Referer":      "/member.php?mod=logging&action=login",
+	}
+
+	respData, err = forumRequest(ctx, "POST", "/"+strings.TrimPrefix(action, "/")+"&inajax=1", formData.Encode(), headers, acc.session)
+	if err != nil {
+		return fmt.Errorf("登录请求失败: %w", err)
+	}
+
+	if !bytes.Contains(respData, []byte("succeedhandle_")) && !bytes.Contains(respData, []byte("欢迎您回来")) {
+		message := "未知错误"
+		if matches := ajaxMessageRegex.FindSubmatch(respData); matches != nil {
+			message = string(matches[1])
+		} else if text := strings.TrimSpace(stripAjax(respData)); text != "" {
+			message = text
+		}
+		return loginRejected("登录失败: %s", strings.TrimPrefix(message, "登录失败，"))
+	}
+
+	if !acc.session.jar.has("_auth") {
+		return loginRejected("登录失败: 论坛没有返回登录 cookie")
+	}
+	acc.logger("login").Info("登录成功")
+	return nil
+}
+
+// stripAjax 去除 Discuz AJAX 返回内容中的 XML 包装与 HTML 标签，返回纯文本
+func stripAjax(data []byte) string {
+	text := string(data)
+	if start := strings.Index(text, "<![CDATA["); start >= 0 {
+		text = text[start+len("<![CDATA["):]
+		text, _, _ = strings.Cut(text, "]]>")
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return text
+	}
+	return doc.Text()
+}
+
+// relogin 在 cookie 失效后使用用户名与密码重新登录，多个任务同时检测到失效时只登录一次。
+// 登录成功时恢复因 cookie 失效而停止的任务。
+func (acc *account) relogin(ctx context.Context) error {
+	if acc.credentials == nil {
+		return ErrNotLoggedIn
+	}
+
+	acc.loginMu.Lock()
+	defer acc.loginMu.Unlock()
+
+	// 其他任务刚刚登录成功或被论坛拒绝，直接使用其结果，避免登录失败时反复尝试导致账户被锁定。
+	// 网络错误等临时问题不缓存，由重试策略决定何时再次登录
+	if time.Since(acc.lastLogin) < time.Minute && (acc.lastLoginErr == nil || isLoginRejected(acc.lastLoginErr)) {
+		return acc.lastLoginErr
+	}
+
+	acc.lastLogin = time.Now()
+	acc.lastLoginErr = login(ctx, acc)
+	if acc.lastLoginErr == nil {
+		stateStore.Flush() // 立即保存登录得到的 cookie
+		if acc.loggedOut.CompareAndSwap(true, false) {
+			acc.logger("login").Info("重新登录成功，恢复该账户的任务")
+		}
+	}
+	return acc.lastLoginErr
+}
+
+// retryAfterLogin 执行任务，任务因未登录失败且账户配置了用户名与密码时，重新登录后再执行一次。
+// 只有论坛拒绝登录时才返回 ErrNotLoggedIn，网络错误等临时问题按原错误返回，由重试策略处理。
+func retryAfterLogin[T any](ctx context.Context, acc *account, task func(ctx context.Context, acc *account) (T, error)) (T, error) {
+	result, err := task(ctx, acc)
+	if !errors.Is(err, ErrNotLoggedIn) || acc.credentials == nil {
+		return result, err
+	}
+
+	acc.logger("login").Warn("cookie 已失效，尝试重新登录")
+	if loginErr := acc.relogin(ctx); loginErr != nil {
+		if isLoginRejected(loginErr) {
+			return result, fmt.Errorf("%w，自动登录失败: %v", ErrNotLoggedIn, loginErr)
+		}
+		return result, fmt.Errorf("自动登录失败: %w", loginErr)
+	}
+	return task(ctx, acc)
+}
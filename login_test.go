@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testForum 定义模拟 Discuz 登录流程的测试论坛
+type testForum struct {
+	password   string // 正确的密码
+	captcha    bool   // 登录表单需要验证码
+	noAuth     bool   // 登录成功但不返回登录 cookie
+	loginError int    // 登录页面返回的 HTTP 状态码，为 0 时正常返回
+
+	logins atomic.Int32 // 提交登录表单的次数
+	posted url.Values   // 最近一次提交的登录表单
+}
+
+// testAuthCookie 定义登录成功后论坛返回的登录 cookie
+const testAuthCookie = "s_gkr8_abcd_auth=valid"
+
+// newTestForum 启动测试论坛，将论坛地址指向该论坛并使用空的状态存储
+func newTestForum(t *testing.T, forum *testForum) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(forum)
+	t.Cleanup(server.Close)
+
+	setupMirrors(&Config{BaseURL: server.URL})
+	t.Cleanup(func() { setupMirrors(&Config{}) })
+
+	saved := stateStore
+	stateStore = &StateStore{data: stateData{Accounts: map[string]*AccountState{}}} // 不使用其他测试保存的 cookie
+	t.Cleanup(func() { stateStore = saved })
+	return server
+}
+
+func (f *testForum) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/member.php" && r.Method == "GET":
+		if f.loginError != 0 {
+			w.WriteHeader(f.loginError)
+			return
+		}
+		captcha := ""
+		if f.captcha {
+			captcha = `<input name="seccodeverify">`
+		}
+		fmt.Fprintf(w, `<form name="login" action="member.php?mod=logging&amp;action=login&amp;loginsubmit=yes&amp;loginhash=L1">
+<input type="hidden" name="formhash" value="fh123">%s</form>`, captcha)
+
+	case r.URL.Path == "/member.php" && r.Method == "POST":
+		f.logins.Add(1)
+		r.ParseForm()
+		f.posted = r.PostForm
+		if r.PostForm.Get("password") != f.password {
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><root><![CDATA[<script>errorhandle_('登录失败，密码错误，您还可以尝试 4 次', {'loginperm':'4'});</script>]]></root>`)
+			return
+		}
+		if !f.noAuth {
+			w.Header().Add("Set-Cookie", testAuthCookie+"; path=/; httponly")
+		}
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><root><![CDATA[<script>succeedhandle_('forum.php', '欢迎您回来', {});</script>]]></root>`)
+
+	case r.URL.Path == "/forum.php":
+		uid := "0"
+		if strings.Contains(r.Header.Get("Cookie"), testAuthCookie) {
+			uid = "7"
+		}
+		fmt.Fprintf(w, `<script>var discuz_uid = '%s';</script>`, uid)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestAccount 创建配置了用户名与密码的测试账户
+func newTestAccount(t *testing.T, cookie string) *account {
+	t.Helper()
+	accounts, err := newAccounts(&Config{Account: []AccountConfig{{
+		Name:     t.Name(),
+		Cookie:   cookie,
+		Username: "user",
+		Password: "secret",
+	}}})
+	if err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+	return accounts[0]
+}
+
+func TestLogin(t *testing.T) {
+	forum := &testForum{password: "secret"}
+	newTestForum(t, forum)
+	acc := newTestAccount(t, "")
+
+	if err := login(context.Background(), acc); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if !acc.session.jar.has("_auth") {
+		t.Error("登录后没有保存登录 cookie")
+	}
+	if forum.posted.Get("formhash") != "fh123" || forum.posted.Get("username") != "user" {
+		t.Errorf("提交的表单为 %v", forum.posted)
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	forum := &testForum{password: "other"}
+	newTestForum(t, forum)
+	acc := newTestAccount(t, "")
+
+	err := login(context.Background(), acc)
+	if !isLoginRejected(err) {
+		t.Fatalf("错误应为拒绝登录: %v", err)
+	}
+	if err.Error() != "登录失败: 密码错误，您还可以尝试 4 次" {
+		t.Errorf("错误信息为 %q", err)
+	}
+}
+
+func TestLoginWithoutAuthCookie(t *testing.T) {
+	newTestForum(t, &testForum{password: "secret", noAuth: true})
+	acc := newTestAccount(t, "")
+
+	if err := login(context.Background(), acc); !isLoginRejected(err) {
+		t.Errorf("错误应为拒绝登录: %v", err)
+	}
+}
+
+func TestLoginCaptcha(t *testing.T) {
+	forum := &testForum{password: "secret", captcha: true}
+	newTestForum(t, forum)
+	acc := newTestAccount(t, "")
+
+	err := login(context.Background(), acc)
+	if !isLoginRejected(err) || !strings.Contains(err.Error(), "验证码") {
+		t.Errorf("错误为 %v", err)
+	}
+	if forum.logins.Load() != 0 {
+		t.Error("需要验证码时不应提交登录表单")
+	}
+}
+
+func TestRetryAfterLogin(t *testing.T) {
+	forum := &testForum{password: "secret"}
+	newTestForum(t, forum)
+	acc := newTestAccount(t, "s_gkr8_abcd_auth=expired")
+	acc.loggedOut.Store(true) // 之前自动登录失败而停止的账户
+
+	var calls int
+	uid, err := retryAfterLogin(context.Background(), acc, func(ctx context.Context, acc *account) (string, error) {
+		calls++
+		return checkLogin(ctx, acc)
+	})
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if uid != "7" || calls != 2 || forum.logins.Load() != 1 {
+		t.Errorf("uid 为 %s，任务执行 %d 次，登录 %d 次", uid, calls, forum.logins.Load())
+	}
+	if !acc.active() {
+		t.Error("重新登录成功后应恢复账户的任务")
+	}
+}
+
+func TestRetryAfterLoginRejected(t *testing.T) {
+	newTestForum(t, &testForum{password: "other"})
+	acc := newTestAccount(t, "s_gkr8_abcd_auth=expired")
+
+	_, err := retryAfterLogin(context.Background(), acc, checkLogin)
+	if !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("错误应为 ErrNotLoggedIn: %v", err)
+	}
+}
+
+func TestRetryAfterLoginTemporaryError(t *testing.T) {
+	newTestForum(t, &testForum{password: "secret", loginError: http.StatusBadGateway})
+	acc := newTestAccount(t, "s_gkr8_abcd_auth=expired")
+
+	_, err := retryAfterLogin(context.Background(), acc, checkLogin)
+	if errors.Is(err, ErrNotLoggedIn) || !isRetryable(err) {
+		t.Errorf("服务器错误应可以重试且不视为 cookie 失效: %v", err)
+	}
+}
@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -24,28 +30,42 @@ import (
 
 // Config 定义配置文件结构体
 type Config struct {
-	Account []struct {
-		Name   string `yaml:"name"`
-		Cookie string `yaml:"cookie"`
-	} `yaml:"account"`
-	Push struct {
-		BotToken string `yaml:"bot_token"`
-		ChatID   string `yaml:"chat_id"`
-	} `yaml:"push"`
+	BaseURL  string          `yaml:"base_url"` // 论坛地址，默认为 https://www.tsdm39.com
+	Mirrors  []string        `yaml:"mirrors"`  // 备用镜像地址，按顺序切换
+	Account  []AccountConfig `yaml:"account"`
+	Push     PushConfigs     `yaml:"push"`      // 推送目标列表
+	StateDir string          `yaml:"state_dir"` // 状态文件目录，默认为 data
+	Listen   string          `yaml:"listen"`    // 状态页监听地址，为空时不启动，仅在守护进程模式下生效
+	Log      LogConfig       `yaml:"log"`       // 日志配置
+	PIDFile  string          `yaml:"pid_file"`  // 守护进程的 PID 文件，默认为状态目录下的 tsdmtask.pid
+	Proxy    string          `yaml:"proxy"`     // 访问论坛使用的代理，如 http://127.0.0.1:8080 或 socks5://127.0.0.1:1080
+
+	SaveCookies    *bool                    `yaml:"save_cookies"`    // 是否在状态文件中保存 cookie 集合，在 GitHub Actions 中默认不保存，其他环境默认保存
+	Timeout        TimeoutConfig            `yaml:"timeout"`         // 论坛请求的超时时间
+	Retry          RetryPolicy              `yaml:"retry"`           // 任务请求失败后的重试策略
+	CheckInRetry   RetryPolicy              `yaml:"checkin_retry"`   // 守护进程中零点签到失败后的重试策略
+	RateLimit      RateLimitConfig          `yaml:"rate_limit"`      // 论坛请求的限速配置
+	CheckInRace    CheckInRaceConfig        `yaml:"checkin_race"`    // 守护进程零点抢签到的策略
+	RedPacket      RedPacketConfig          `yaml:"redpacket"`       // 抢红包任务的配置
+	HeaderProfiles map[string]HeaderProfile `yaml:"header_profiles"` // 请求头配置，key 为配置名称
+	HeaderProfile  string                   `yaml:"header_profile"`  // 默认使用的请求头配置名称，为空时使用内置配置
 }
 
-// httpClient 定义全局 HTTP 客户端 (使用 fasthttp)
+// httpClient 定义推送使用的 HTTP 客户端 (使用 fasthttp)，访问论坛使用账户各自的客户端
 var httpClient = &fasthttp.Client{
 	MaxConnsPerHost:     200,
 	MaxIdleConnDuration: 30 * time.Second,
 	MaxConnDuration:     5 * time.Minute,
 }
 
-// 存储每个账户的 formhash，key 为 cookie，value 为 formhash
-var formhashCache sync.Map
+// defaultBaseURL 定义默认的论坛地址
+const defaultBaseURL = "https://www.tsdm39.com"
 
-// accountPostCache 定义每个账户的帖子缓存
-var accountPostCache sync.Map // map[string]*sync.Map
+// forumMirrors 定义论坛地址列表，第一个为 base_url，其余为备用镜像
+var forumMirrors = []string{defaultBaseURL}
+
+// forumMirrorIndex 定义当前使用的论坛地址下标
+var forumMirrorIndex atomic.Int32
 
 // loadConfig 从配置文件加载配置
 func loadConfig(configPath string) (*Config, error) {
@@ -63,19 +83,73 @@ func loadConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
-// sendRequest 发送 HTTP 请求
-func sendRequest(method, url string, body string, headers map[string]string, cookie string) ([]byte, error) {
+// stateDir 返回状态文件目录
+func (c *Config) stateDir() string {
+	if c.StateDir == "" {
+		return "data"
+	}
+	return c.StateDir
+}
+
+// saveCookies 返回是否在状态文件中保存 cookie 集合。
+// GitHub Actions 会缓存状态目录，其他分支与 fork 的 PR 也能恢复该缓存，因此默认不保存登录 cookie。
+func (c *Config) saveCookies() bool {
+	if c.SaveCookies != nil {
+		return *c.SaveCookies
+	}
+	return os.Getenv("GITHUB_ACTIONS") != "true"
+}
+
+// pidFile 返回守护进程的 PID 文件路径
+func (c *Config) pidFile() string {
+	if c.PIDFile == "" {
+		return filepath.Join(c.stateDir(), "tsdmtask.pid")
+	}
+	return c.PIDFile
+}
+
+// setupMirrors 根据配置初始化论坛地址列表
+func setupMirrors(config *Config) {
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	mirrors := []string{baseURL}
+	for _, mirror := range config.Mirrors {
+		mirror = strings.TrimRight(mirror, "/")
+		if mirror != "" && !slices.Contains(mirrors, mirror) {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+
+	forumMirrors = mirrors
+	forumMirrorIndex.Store(0)
+}
+
+// sendRequest 使用账户的 HTTP 客户端发送请求，附带账户在请求主机下的 cookie，并将响应中的 Set-Cookie 保存到该主机的 cookie 中。
+// 请求按全局与账户的限速发送，在超过账户的请求超时时间或 ctx 取消时返回错误。
+func sendRequest(ctx context.Context, method, url string, body string, headers map[string]string, session *forumSession) ([]byte, error) {
+	// 等待限速器允许后再发送请求，等待时间不计入请求超时
+	if err := waitRateLimit(ctx, session); err != nil {
+		return nil, err
+	}
+
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
 
 	req.SetRequestURI(url)
 	req.Header.SetMethod(method)
 
-	if cookie != "" {
+	// 只附带请求的镜像主机的 cookie
+	host := string(req.URI().Host())
+	if cookie := session.jar.header(host); cookie != "" {
 		req.Header.Set("Cookie", cookie)
 	}
+	// 先设置账户的请求头配置，请求自身的请求头可以覆盖
+	for k, v := range session.headers {
+		req.Header.Set(k, v)
+	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
@@ -84,44 +158,128 @@ func sendRequest(method, url string, body string, headers map[string]string, coo
 		req.SetBodyString(body)
 	}
 
-	err := httpClient.Do(req, resp)
+	deadline := time.Now().Add(session.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- session.client.DoDeadline(req, resp, deadline)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		// 请求仍在进行，req 与 resp 不能放回对象池，交由 GC 回收；请求被主动中断，不记录为请求失败
+		return nil, ctx.Err()
+	case err = <-done:
+	}
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
 	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+		observeRequest(url, 0, start)
+		return nil, temporary(fmt.Errorf("发送请求失败: %w", err))
+	}
+	observeRequest(url, resp.StatusCode(), start)
+	forumClock.observe(start, time.Now(), string(resp.Header.Peek(fasthttp.HeaderDate)))
+	session.jar.update(host, resp)
+
+	if statusCode := resp.StatusCode(); statusCode >= 500 {
+		return nil, temporary(fmt.Errorf("服务器错误: HTTP %d", statusCode))
 	}
 
-	return resp.Body(), nil
+	// resp 会在函数返回后被回收，需要复制一份响应内容
+	return append([]byte(nil), resp.Body()...), nil
 }
 
-// tsdmCheckIn 执行天使动漫论坛签到
-func tsdmCheckIn(cookie string) (string, error) {
-	var formhash string
-	var ok bool
-	var retryCount int
-
-	// 尝试从缓存中获取 formhash
-	if cachedFormhash, loaded := formhashCache.Load(cookie); loaded {
-		if formhash, ok = cachedFormhash.(string); ok {
-			// 使用缓存的 formhash 进行签到操作，最多重试 3 次
-			for retryCount < 3 {
-				result, err := doCheckIn(cookie, formhash)
-				if err == nil {
-					return result, nil
-				}
-				retryCount++
-				fmt.Println("签到失败，重试次数:", retryCount)
-				time.Sleep(1 * time.Second) // 等待 1 秒后重试
+// forumRequest 向论坛发送 HTTP 请求，path 为相对于论坛地址的路径，请求附带账户在该镜像域名下的 cookie。
+// 从当前使用的地址开始依次尝试，遇到连接错误、TLS 错误或服务器错误时切换到下一个镜像，
+// 请求成功后记住该镜像，后续请求优先使用。
+func forumRequest(ctx context.Context, method, path string, body string, headers map[string]string, session *forumSession) ([]byte, error) {
+	mirrors := forumMirrors
+	start := int(forumMirrorIndex.Load()) % len(mirrors)
+
+	var lastErr error
+	for i := range mirrors {
+		index := (start + i) % len(mirrors)
+		baseURL := mirrors[index]
+
+		// Cookie 随请求发往实际使用的镜像，Origin 与 Referer 也需与该镜像一致，否则论坛会拒绝请求
+		respData, err := sendRequest(ctx, method, baseURL+path, body, mirrorHeaders(baseURL, headers), session)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			// 连接、TLS 握手、读写失败或服务器返回 5xx 时切换到下一个镜像
+			slog.Warn("论坛地址请求失败", "base_url", baseURL, "error", err)
+			lastErr = err
+			continue
+		}
+
+		if index != start && forumMirrorIndex.CompareAndSwap(int32(start), int32(index)) {
+			slog.Info("已切换到论坛地址", "base_url", baseURL)
+		}
+		return respData, nil
+	}
+
+	return nil, lastErr
+}
+
+// mirrorHeaders 将 headers 中以 "/" 开头的 Origin 与 Referer 补全为指定镜像的地址
+func mirrorHeaders(baseURL string, headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	resolved := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if (k == "Origin" || k == "Referer") && strings.HasPrefix(v, "/") {
+			v = baseURL + v
+			if k == "Origin" {
+				v = strings.TrimSuffix(v, "/")
 			}
-			// 重试 3 次后仍然失败，重新获取 formhash
-			fmt.Println("签到失败，重新获取 formhash")
-			formhashCache.Delete(cookie) // 删除缓存的 formhash
 		}
+		resolved[k] = v
+	}
+	return resolved
+}
+
+// tsdmCheckIn 执行天使动漫论坛签到
+func tsdmCheckIn(ctx context.Context, acc *account) (CheckInResult, error) {
+	// 尝试从状态存储中获取 formhash
+	if formhash, ok := stateStore.Formhash(acc.Name); ok {
+		// 使用缓存的 formhash 进行签到操作，临时错误由重试策略处理
+		result, err := doCheckIn(ctx, acc.session, formhash)
+		if err == nil || isRetryable(err) || errors.Is(err, ErrNotLoggedIn) || ctx.Err() != nil {
+			return result, err
+		}
+		// 签到被论坛拒绝，formhash 可能已过期，重新获取 formhash
+		acc.logger("checkin").Info("签到失败，重新获取 formhash", "error", err)
+		stateStore.SetFormhash(acc.Name, "") // 删除缓存的 formhash
 	}
 
 	// 如果缓存中没有 formhash 或 formhash 过期，则发送请求获取
-	respData, err := sendRequest("GET", "https://www.tsdm39.com/forum.php", "", nil, cookie)
+	formhash, err := fetchFormhash(ctx, acc)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	// 使用新获取的 formhash 进行签到操作
+	return doCheckIn(ctx, acc.session, formhash)
+}
+
+// fetchFormhash 请求论坛首页获取 formhash，并保存到状态存储中
+func fetchFormhash(ctx context.Context, acc *account) (string, error) {
+	respData, err := forumRequest(ctx, "GET", "/forum.php", "", nil, acc.session)
 	if err != nil {
 		return "", fmt.Errorf("获取页面内容失败: %w", err)
 	}
+	if err := checkLoginPage(respData); err != nil {
+		return "", err
+	}
 
 	// 使用 goquery 解析 HTML 代码
 	contentType := mimetype.Detect(respData).String()
@@ -141,18 +299,13 @@ func tsdmCheckIn(cookie string) (string, error) {
 		return "", fmt.Errorf("formhash 不存在")
 	}
 
-	// 将 formhash 存储到缓存中，并设置过期时间
-	formhashCache.Store(cookie, formhash)
-	time.AfterFunc(30*24*time.Hour, func() {
-		formhashCache.Delete(cookie)
-	})
-
-	// 使用新获取的 formhash 进行签到操作
-	return doCheckIn(cookie, formhash)
+	// 将 formhash 保存到状态存储中，有效期为 30 天
+	stateStore.SetFormhash(acc.Name, formhash)
+	return formhash, nil
 }
 
 // doCheckIn 使用指定的 formhash 执行签到操作
-func doCheckIn(cookie, formhash string) (string, error) {
+func doCheckIn(ctx context.Context, session *forumSession, formhash string) (CheckInResult, error) {
 	// 签到
 	formData := url.Values{
 		"formhash":  {formhash},
@@ -164,12 +317,15 @@ func doCheckIn(cookie, formhash string) (string, error) {
 
 	headers := map[string]string{
 		"Content-Type": "application/x-www-form-urlencoded",
-		"Origin":       "https://www.tsdm39.com",
+		"Origin":       "/",
 	}
 
-	respData, err := sendRequest("POST", "https://www.tsdm39.com/plugin.php?id=dsu_paulsign%3Asign&operation=qiandao&infloat=1&sign_as=1&inajax=1", formData.Encode(), headers, cookie)
+	respData, err := forumRequest(ctx, "POST", "/plugin.php?id=dsu_paulsign%3Asign&operation=qiandao&infloat=1&sign_as=1&inajax=1", formData.Encode(), headers, session)
 	if err != nil {
-		return "", fmt.Errorf("签到请求失败: %w", err)
+		return CheckInResult{}, fmt.Errorf("签到请求失败: %w", err)
+	}
+	if err := checkLoginPage(respData); err != nil {
+		return CheckInResult{}, err
 	}
 
 	// 检查签到结果
@@ -180,12 +336,12 @@ func doCheckIn(cookie, formhash string) (string, error) {
 	contentType := mimetype.Detect(respData).String()
 	reader, err := charset.NewReader(strings.NewReader(string(respData)), contentType)
 	if err != nil {
-		return "", fmt.Errorf("创建 reader 失败: %w", err)
+		return CheckInResult{}, fmt.Errorf("创建 reader 失败: %w", err)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(reader)
 	if err != nil {
-		return "", fmt.Errorf("解析 HTML 失败: %w", err)
+		return CheckInResult{}, fmt.Errorf("解析 HTML 失败: %w", err)
 	}
 
 	// 查找包含签到结果的 div 元素
@@ -214,46 +370,38 @@ func doCheckIn(cookie, formhash string) (string, error) {
 	var ranking int
 	if len(rankingMatch) > 1 {
 		ranking, _ = strconv.Atoi(rankingMatch[1])
-	} else {
-		// 如果没有匹配到排名信息，则将排名设置为 -1
-		ranking = -1
 	}
 
 	if checkInSuccessRegex.MatchString(resultText) {
-		if extraReward > 0 {
-			if ranking != -1 { // 如果有排名信息
-				return fmt.Sprintf("签到成功，您是今天第 %d 个签到的会员，获得天使币 %d (包含额外奖励 %d)", ranking, totalAngelCoins, extraReward), nil
-			} else { // 如果没有排名信息
-				return fmt.Sprintf("签到成功，获得天使币 %d (包含额外奖励 %d)", totalAngelCoins, extraReward), nil
-			}
-		} else {
-			if ranking != -1 { // 如果有排名信息
-				return fmt.Sprintf("签到成功，您是今天第 %d 个签到的会员，获得天使币 %d", ranking, totalAngelCoins), nil
-			} else { // 如果没有排名信息
-				return fmt.Sprintf("签到成功，获得天使币 %d", totalAngelCoins), nil
-			}
-		}
+		return CheckInResult{
+			Status:     CheckInSuccess,
+			Rank:       ranking,
+			Coins:      totalAngelCoins,
+			ExtraCoins: extraReward,
+		}, nil
 	} else if alreadyRegex.MatchString(resultText) {
-		return "您今天已经签到", nil
+		return CheckInResult{Status: CheckInAlready}, nil
 	} else {
-		return "", fmt.Errorf("签到失败: %s", resultText)
+		return CheckInResult{}, forumError("签到失败", strings.TrimSpace(resultText))
 	}
 }
 
 // tsdmWork 执行天使动漫论坛打工任务
-func tsdmWork(accountName, cookie string) (bool, time.Duration, error) {
+func tsdmWork(ctx context.Context, acc *account) (WorkResult, error) {
 	headers := map[string]string{
-		"User-Agent":       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
 		"Connection":       "Keep-Alive",
 		"X-Requested-With": "XMLHttpRequest",
-		"Referer":          "https://www.tsdm39.net/plugin.php?id=np_cliworkdz:work",
+		"Referer":          "/plugin.php?id=np_cliworkdz:work",
 		"Content-Type":     "application/x-www-form-urlencoded",
 	}
 
 	// 检查是否可以打工
-	data, err := sendRequest("GET", "https://www.tsdm39.com/plugin.php?id=np_cliworkdz%3Awork&inajax=1", "", headers, cookie)
+	data, err := forumRequest(ctx, "GET", "/plugin.php?id=np_cliworkdz%3Awork&inajax=1", "", headers, acc.session)
 	if err != nil {
-		return false, 0, fmt.Errorf("检查打工状态失败: %w", err)
+		return WorkResult{}, fmt.Errorf("检查打工状态失败: %w", err)
+	}
+	if err := checkLoginPage(data); err != nil {
+		return WorkResult{}, err
 	}
 
 	waitRegex := regexp.MustCompile(`您需要等待(\d+)小时(\d+)分钟(\d+)秒后即可进行。`)
@@ -263,7 +411,7 @@ func tsdmWork(accountName, cookie string) (bool, time.Duration, error) {
 		minutes, _ := strconv.Atoi(matches[2])
 		seconds, _ := strconv.Atoi(matches[3])
 		waitDuration := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
-		return false, waitDuration, nil // 返回计算出的等待时间
+		return WorkResult{Status: WorkWaiting, Wait: waitDuration}, nil // 返回计算出的等待时间
 	}
 
 	// 打工
@@ -271,40 +419,46 @@ func tsdmWork(accountName, cookie string) (bool, time.Duration, error) {
 	ticker := time.NewTicker(3 * time.Second) // 使用 ticker 控制打工请求间隔
 	defer ticker.Stop()
 	for i := 0; i < 6; i++ {
-		<-ticker.C // 等待 ticker 事件
-		_, err := sendRequest("POST", "https://www.tsdm39.com/plugin.php?id=np_cliworkdz:work", formData.Encode(), headers, cookie)
+		// 等待 ticker 事件，收到退出信号时中断打工
+		select {
+		case <-ctx.Done():
+			return WorkResult{}, ctx.Err()
+		case <-ticker.C:
+		}
+		_, err := forumRequest(ctx, "POST", "/plugin.php?id=np_cliworkdz:work", formData.Encode(), headers, acc.session)
 		if err != nil {
-			fmt.Printf("[%s] 打工请求失败: %v\n", accountName, err)
-			return false, 0, fmt.Errorf("打工请求失败: %w", err)
+			return WorkResult{}, fmt.Errorf("打工请求失败: %w", err)
 		}
 	}
 
 	// 获取奖励
 	formData = url.Values{"act": {"getcre"}}
-	data, err = sendRequest("POST", "https://www.tsdm39.com/plugin.php?id=np_cliworkdz:work", formData.Encode(), headers, cookie)
+	data, err = forumRequest(ctx, "POST", "/plugin.php?id=np_cliworkdz:work", formData.Encode(), headers, acc.session)
 	if err != nil {
-		fmt.Printf("[%s] 获取奖励失败: %v\n", accountName, err)
-		return false, 0, fmt.Errorf("获取奖励失败: %w", err)
+		return WorkResult{}, fmt.Errorf("获取奖励失败: %w", err)
 	}
 
 	// 检查是否打工成功
-	workSuccessRegex := regexp.MustCompile(`恭喜，您已经成功领取了奖励天使币 \+\d+`)
-	if workSuccessRegex.MatchString(string(data)) {
-		fmt.Printf("[%s] 打工成功\n", accountName)
+	workSuccessRegex := regexp.MustCompile(`恭喜，您已经成功领取了奖励天使币 \+(\d+)`)
+	if matches := workSuccessRegex.FindStringSubmatch(string(data)); matches != nil {
+		acc.logger("work").Info("打工成功")
 
-		return true, 6 * time.Hour, nil // 打工成功后，返回 6 小时的等待时间
+		coins, _ := strconv.Atoi(matches[1])
+		return WorkResult{Status: WorkSuccess, Coins: coins, Wait: 6 * time.Hour}, nil // 打工成功后，返回 6 小时的等待时间
 	}
 
-	fmt.Printf("[%s] 打工失败: %s\n", accountName, string(data))
-	return false, 0, fmt.Errorf("打工失败: %s", string(data))
+	return WorkResult{}, forumError("打工失败", stripAjax(data))
 }
 
 // getScore 获取用户天使币数量
-func getScore(cookie string) (string, error) {
-	respData, err := sendRequest("GET", "https://www.tsdm39.com/home.php?mod=spacecp&ac=credit&showcredit=1", "", nil, cookie)
+func getScore(ctx context.Context, acc *account) (string, error) {
+	respData, err := forumRequest(ctx, "GET", "/home.php?mod=spacecp&ac=credit&showcredit=1", "", nil, acc.session)
 	if err != nil {
 		return "", fmt.Errorf("获取积分信息失败: %w", err)
 	}
+	if err := checkLoginPage(respData); err != nil {
+		return "", err
+	}
 
 	// 使用 mimetype 检测内容类型
 	contentType := mimetype.Detect(respData).String()
@@ -320,111 +474,110 @@ func getScore(cookie string) (string, error) {
 	// 查找包含天使币数量的 li 元素
 	angelCoins := doc.Find(".creditl .xi1").First().Text()
 	angelCoins = strings.TrimSpace(strings.Replace(angelCoins, "天使币:", "", 1))
+	observeScore(acc.Name, angelCoins)
 
 	return angelCoins, nil
 }
 
-// checkPosts 检查帖子列表并尝试抢红包
-func checkPosts(accountName string, cookie string, botToken string, chatID string) {
-	// 获取账户的缓存
-	var postCache *sync.Map
-	if cache, ok := accountPostCache.Load(accountName); ok {
-		postCache = cache.(*sync.Map)
-	} else {
-		postCache = &sync.Map{}
-		accountPostCache.Store(accountName, postCache)
+// checkPosts 检查配置的版块中的帖子并尝试抢红包
+func checkPosts(ctx context.Context, acc *account) {
+	if !acc.active() {
+		return
 	}
 
-	// 获取帖子列表页面
-	respData, err := sendRequest("GET", "https://www.tsdm39.com/forum.php?mod=forumdisplay&fid=4", "", nil, cookie)
+	// 获取所有版块的帖子，不同版块中的同一帖子只处理一次
+	threads, err := collectThreads(ctx, acc)
 	if err != nil {
-		fmt.Println("获取帖子列表页面失败:", err)
 		return
 	}
 
-	// 使用 goquery 解析 HTML 代码
-	contentType := mimetype.Detect(respData).String()
-	reader, err := charset.NewReader(strings.NewReader(string(respData)), contentType)
-	if err != nil {
-		fmt.Println("创建 reader 失败:", err)
+	var wg sync.WaitGroup // 创建 WaitGroup
+
+	for _, thread := range threads {
+		wg.Add(1) // 为每个 goroutine 增加计数
+
+		// 使用 goroutine 并行处理抢红包任务
+		go func(thread forumThread) {
+			defer wg.Done() // 在 goroutine 结束时减少计数
+			claimThread(ctx, acc, thread)
+		}(thread)
+	}
+	wg.Wait() // 等待所有 goroutine 执行完毕
+
+	// 将帖子记录写入状态文件
+	stateStore.Flush()
+}
+
+// claimThread 判断帖子是否有红包并尝试领取，处理过的帖子记录到状态存储中，需调用 Flush 写入文件
+func claimThread(ctx context.Context, acc *account, thread forumThread) {
+	tid := thread.TID
+	// 检查帖子是否已处理过，7 天内处理过或其他任务正在处理的帖子不再重复请求
+	if !stateStore.ClaimThread(acc.Name, tid) {
 		return
 	}
+	defer stateStore.ReleaseThread(acc.Name, tid)
 
-	doc, err := goquery.NewDocumentFromReader(reader)
+	// 判断帖子是否有红包，没有红包的帖子不再请求
+	redPacket, probe, err := detectRedPacket(ctx, acc, thread)
+	if ctx.Err() != nil {
+		return // 程序退出，下次重新检查该帖子
+	}
 	if err != nil {
-		fmt.Println("解析 HTML 失败:", err)
+		ledger.recordRedPacket(acc.Name, thread, RedPacketResult{}, err)
+		return // 探测失败，下次重新检查该帖子
+	}
+	if probe != nil {
+		ledger.recordRedPacket(acc.Name, thread, *probe, nil)
+	}
+	if !redPacket {
+		stateStore.MarkThread(acc.Name, tid)
 		return
 	}
 
-	var wg sync.WaitGroup // 创建 WaitGroup
-
-	// 查找帖子列表
-	doc.Find("tbody[id^='normalthread_']").Each(func(i int, s *goquery.Selection) {
-		// 提取帖子链接
-		link, exists := s.Find("th a.xst").Attr("href")
-		if !exists {
-			fmt.Println("帖子链接不存在")
-			return
+	// 帖子有红包，尝试抢红包，探测时已经领取过的不再请求
+	var redPacketResult RedPacketResult
+	if probe != nil {
+		redPacketResult = *probe
+	} else {
+		redPacketResult, err = retryTask(ctx, acc, func(ctx context.Context, acc *account) (RedPacketResult, error) {
+			return grabRedPacket(ctx, acc, tid)
+		})
+		if ctx.Err() != nil {
+			return // 程序退出，下次重新检查该帖子
 		}
-
-		wg.Add(1) // 为每个 goroutine 增加计数
-
-		// 提取帖子 ID
-		tidRegex := regexp.MustCompile(`tid=(\d+)`)
-		tidMatches := tidRegex.FindStringSubmatch(link)
-		if len(tidMatches) <= 1 {
-			fmt.Println("帖子 ID 不存在")
-			return
+		ledger.recordRedPacket(acc.Name, thread, redPacketResult, err)
+	}
+	if err != nil {
+		// 不输出错误信息
+	} else {
+		if !redPacketResult.Status.claimable() {
+			stateStore.SetThreadRedPacket(tid, false) // 带有标记但没有红包或红包已被抢光，其他账户不再请求
 		}
-		tid := tidMatches[1]
-
-		// 使用 goroutine 并行处理抢红包任务
-		go func(tid string) {
-			defer wg.Done() // 在 goroutine 结束时减少计数
-			// 检查缓存
-			if cached, ok := postCache.Load(tid); ok {
-				// 缓存存在，检查缓存创建时间是否超过 3 天
-				if time.Since(cached.(time.Time)) > 3*24*time.Hour {
-					// 缓存创建时间超过 3 天，刷新缓存时间，延长至 7 天
-					postCache.Store(tid, time.Now())
-					time.AfterFunc(7*24*time.Hour, func() {
-						postCache.Delete(tid)
-					})
-					return
-				} else {
-					// 缓存创建时间未超过 3 天，不做任何操作
-					return
-				}
-			}
-			// 缓存不存在或已过期，尝试抢红包
-			redPacketAngelCoins, redPacketResult, err := grabRedPacket(tid, cookie)
-			if err != nil {
-				// 不输出错误信息
-			} else {
-				// 如果抢到红包，推送消息
-				if redPacketAngelCoins > 0 {
-					push(fmt.Sprintf("[%s] %s", accountName, redPacketResult), botToken, chatID)
-				}
-			}
+		// 如果抢到红包，推送消息
+		if redPacketResult.Status == RedPacketGrabbed {
+			acc.summary.recordRedPacket(redPacketResult.Coins)
+			acc.status.recordRedPacket(redPacketResult)
+			acc.push(EventRedPacket, redPacketResult.String())
+		}
+	}
 
-			// 更新缓存并设置过期时间为 7 天
-			postCache.Store(tid, time.Now())
-			time.AfterFunc(7*24*time.Hour, func() {
-				postCache.Delete(tid)
-			})
-		}(tid)
-	})
-	wg.Wait() // 等待所有 goroutine 执行完毕
+	// 记录帖子已处理
+	stateStore.MarkThread(acc.Name, tid)
 }
 
 // grabRedPacket 尝试抢红包
-func grabRedPacket(tid string, cookie string) (int, string, error) {
-	redPacketURL := fmt.Sprintf("https://tsdm39.com/plugin.php?id=tsdmbet:awardPacket&action=getaward&tid=%s", tid)
+func grabRedPacket(ctx context.Context, acc *account, tid string) (result RedPacketResult, err error) {
+	defer func() { observeRedPacket(acc.Name, result, err) }()
+
+	redPacketPath := fmt.Sprintf("/plugin.php?id=tsdmbet:awardPacket&action=getaward&tid=%s", tid)
 
 	// 发送红包请求
-	respData, err := sendRequest("GET", redPacketURL, "", nil, cookie)
+	respData, err := forumRequest(ctx, "GET", redPacketPath, "", nil, acc.session)
 	if err != nil {
-		return 0, "", fmt.Errorf("红包请求失败: %w", err)
+		return RedPacketResult{}, fmt.Errorf("红包请求失败: %w", err)
+	}
+	if err := checkLoginPage(respData); err != nil {
+		return RedPacketResult{}, err
 	}
 
 	// 检查红包结果
@@ -433,272 +586,354 @@ func grabRedPacket(tid string, cookie string) (int, string, error) {
 	redPacketAlreadyRegex := regexp.MustCompile(`已经领取过这个主题的红包了`)
 	redPacketNoRedPacketRegex := regexp.MustCompile(`这个主题并没有红包`)
 
+	result = RedPacketResult{TID: tid}
 	if redPacketSuccessRegex.MatchString(string(respData)) {
 		matches := redPacketSuccessRegex.FindStringSubmatch(string(respData))
-		redPacketAngelCoins, _ := strconv.Atoi(matches[1])
-		return redPacketAngelCoins, fmt.Sprintf("抢到红包啦！获得 %d 天使币", redPacketAngelCoins), nil
+		result.Status = RedPacketGrabbed
+		result.Coins, _ = strconv.Atoi(matches[1])
 	} else if redPacketFailRegex.MatchString(string(respData)) {
-		return 0, "来晚了，红包已被抢光", nil
+		result.Status = RedPacketLate
 	} else if redPacketAlreadyRegex.MatchString(string(respData)) {
-		return 0, "您已领取过此红包", nil
+		result.Status = RedPacketAlready
 	} else if redPacketNoRedPacketRegex.MatchString(string(respData)) {
-		return 0, "这个主题并没有红包", nil
+		result.Status = RedPacketNone
 	} else {
-		return 0, "", fmt.Errorf("未知错误: %s", string(respData))
+		return RedPacketResult{}, forumError("未知错误", stripAjax(respData))
 	}
+	return result, nil
 }
 
-// telegramPush 发送 Telegram 推送消息
-func telegramPush(sendTitle, pushMessage, botToken, chatID string) error {
-	formData := url.Values{
-		"chat_id": {chatID},
-		"text":    {sendTitle + "\r\n" + pushMessage},
-	}
+// pushCheckInResult 推送签到结果
+func pushCheckInResult(acc *account, result CheckInResult) {
+	acc.summary.recordCheckIn(result.String())
+	acc.status.recordCheckIn(result)
+	stateStore.SetLastCheckIn(acc.Name, forumClock.now())
 
-	headers := map[string]string{
-		"Content-Type": "application/x-www-form-urlencoded",
+	if result.Status == CheckInSuccess {
+		acc.push(EventCheckInSuccess, "签到结果: "+result.String())
+	} else {
+		acc.push(EventCheckInAlready, "签到结果: "+result.String())
 	}
+}
 
-	_, err := sendRequest("POST", "https://api.telegram.org/bot"+botToken+"/sendMessage", formData.Encode(), headers, "")
-	if err != nil {
-		return fmt.Errorf("telegram 推送失败: %w", err)
+// pushCheckInFailure 推送签到失败信息
+func pushCheckInFailure(acc *account, err error) {
+	if acc.handleLoginError(err) {
+		return
 	}
-	return nil
+	acc.summary.recordError()
+	acc.status.recordCheckInError(err)
+	acc.push(EventCheckInFailure, fmt.Sprintf("签到失败: %v", err))
 }
 
-// push 发送推送消息
-func push(data, botToken, chatID string) {
-	go func() {
-		err := telegramPush("【天使动漫论坛任务推送】", data, botToken, chatID)
-		if err != nil {
-			fmt.Println(err)
-		}
-	}()
+// pushWorkResult 推送打工结果
+func pushWorkResult(acc *account, result WorkResult, score string) {
+	if result.Status == WorkSuccess {
+		acc.summary.recordWork()
+		acc.status.recordWork()
+		acc.push(EventWorkSuccess, fmt.Sprintf("%s，已拥有天使币数量: %s", result, score))
+	}
 }
 
-// pushCheckInResult 推送签到结果
-func pushCheckInResult(accountName, result, botToken, chatID string) {
-	// 只在签到成功时推送消息
-	checkInSuccessRegex := regexp.MustCompile(`签到成功`)
-	if checkInSuccessRegex.MatchString(result) {
-		push(fmt.Sprintf("[%s] 签到结果: %s", accountName, result), botToken, chatID)
+// pushWorkFailure 推送打工失败信息，连续失败时只在第一次失败时调用
+func pushWorkFailure(acc *account, err error) {
+	if acc.handleLoginError(err) {
+		return
 	}
+	acc.summary.recordError()
+	acc.status.recordError("打工", err)
+	acc.push(EventWorkFailure, fmt.Sprintf("打工失败: %v", err))
 }
 
-// pushWorkResult 推送打工结果
-func pushWorkResult(accountName string, success bool, score, botToken, chatID string) {
-	if success {
-		push(fmt.Sprintf("[%s] 打工成功，已拥有天使币数量: %s", accountName, score), botToken, chatID)
+// runCheckIn 运行签到任务，今日已签到过的账户不再发送请求
+func runCheckIn(ctx context.Context, acc *account) {
+	if !acc.active() {
+		return
+	}
+	if stateStore.LastCheckIn(acc.Name) == forumDate(forumClock.now()) {
+		acc.logger("checkin").Info("今日已签到，跳过签到")
+		return
 	}
-}
 
-// runCheckIn 运行签到任务
-func runCheckIn(accountName, cookie, botToken, chatID string) {
-	checkInResult, err := tsdmCheckIn(cookie)
+	checkInResult, err := retryTask(ctx, acc, tsdmCheckIn)
+	if ctx.Err() != nil {
+		return // 程序退出，不记录为签到失败
+	}
+	observeCheckIn(acc.Name, checkInResult, err)
 	if err != nil {
-		fmt.Printf("[%s] 签到错误: %v\n", accountName, err)
+		acc.logger("checkin").Error("签到失败", "error", err)
+		pushCheckInFailure(acc, err)
 	} else {
-		fmt.Printf("[%s] %s\n", accountName, checkInResult)
-		pushCheckInResult(accountName, checkInResult, botToken, chatID)
+		acc.logger("checkin").Info(checkInResult.String(), "status", checkInResult.Status, "rank", checkInResult.Rank, "coins", checkInResult.Coins)
+		pushCheckInResult(acc, checkInResult)
 	}
 }
 
-// runWork 运行打工任务
-func runWork(accountName, cookie, botToken, chatID string) time.Duration {
-	workSuccess, waitDuration, err := tsdmWork(accountName, cookie)
+// runWork 运行打工任务，返回距离下次打工的时间。
+// 未到记录的下次打工时间时不发送请求，直接返回剩余的等待时间；
+// 打工失败时按 workFailureBackoff 逐渐延长等待时间，连续失败只推送一次失败信息。
+func runWork(ctx context.Context, acc *account) time.Duration {
+	if !acc.active() {
+		return time.Hour
+	}
+	if nextWork := stateStore.NextWork(acc.Name); time.Now().Before(nextWork) {
+		acc.logger("work").Info("未到打工时间", "next_work", nextWork.In(forumLocation).Format(time.DateTime))
+		return time.Until(nextWork)
+	}
+
+	workResult, err := retryTask(ctx, acc, tsdmWork)
+	if ctx.Err() != nil {
+		return 0 // 程序退出，不记录为打工失败
+	}
+	observeWork(acc.Name, workResult, err)
+	if err != nil || workResult.Status == WorkSuccess {
+		ledger.recordWork(acc.Name, workResult, err)
+	}
+
+	waitDuration := workResult.Wait
 	if err != nil {
-		fmt.Printf("[%s] 打工错误: %v\n", accountName, err)
-		//push(fmt.Sprintf("[%s] 打工失败: %v", accountName, err), botToken, chatID) // 推送打工失败信息
+		acc.workFailures++
+		waitDuration = workFailureBackoff.delay(acc.workFailures)
+		acc.logger("work").Error("打工失败", "error", err, "failures", acc.workFailures, "retry_in", waitDuration.Round(time.Second))
+		if acc.workFailures == 1 {
+			pushWorkFailure(acc, err)
+		} else if !acc.handleLoginError(err) {
+			acc.status.recordError("打工", err)
+		}
 	} else {
-		score, scoreErr := getScore(cookie)
+		acc.workFailures = 0
+
+		score, scoreErr := retryTask(ctx, acc, getScore)
 		if scoreErr != nil {
-			fmt.Printf("[%s] 获取天使币数量失败: %v\n", accountName, scoreErr)
+			acc.logger("score").Error("获取天使币数量失败", "error", scoreErr)
+			if !acc.handleLoginError(scoreErr) {
+				acc.status.recordError("天使币", scoreErr)
+			}
 		} else {
-			fmt.Printf("[%s] 天使币数量: %s\n", accountName, score)
-			if workSuccess { // 只在打工成功时推送打工成功信息
-				pushWorkResult(accountName, workSuccess, score, botToken, chatID)
+			acc.logger("score").Info("天使币数量", "score", score)
+			acc.status.recordScore(score)
+			if workResult.Status == WorkSuccess { // 只在打工成功时推送打工成功信息
+				pushWorkResult(acc, workResult, score)
 			}
 		}
 
-		if !workSuccess && waitDuration == 0 {
+		// 记录下次可以打工的时间
+		if waitDuration > 0 {
+			stateStore.SetNextWork(acc.Name, time.Now().Add(waitDuration))
+		}
+
+		if workResult.Status != WorkSuccess && waitDuration == 0 {
 			waitDuration = 1 * time.Minute
 		}
 
-		fmt.Printf("[%s] 下次打工将在 %s 后进行\n", accountName, waitDuration)
+		acc.logger("work").Info("下次打工时间", "wait", waitDuration, "next_work", time.Now().Add(waitDuration).In(forumLocation).Format(time.DateTime))
 	}
 	return waitDuration
 }
 
-// run 运行程序
-func run(config *Config, daemonMode bool) {
-	if daemonMode {
-		// 守护进程模式
-		if os.Getppid() != 1 {
-			// 创建子进程并退出父进程
-			pid, err := syscall.ForkExec(os.Args[0], os.Args, &syscall.ProcAttr{
-				Files: []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()},
-			})
-			if err != nil {
-				fmt.Println("无法创建子进程:", err)
-				return
-			}
-			fmt.Printf("后台进程已启动，PID: %d\n", pid)
-			os.Exit(0)
-		}
-
-		//丢弃输出
-		devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
-		if err != nil {
-			fmt.Println("无法打开 /dev/null:", err)
-			return
-		}
-		defer devNull.Close()
+// setup 初始化日志、账户与状态存储，返回的 io.Closer 用于关闭日志文件
+func setup(config *Config) ([]*account, io.Closer, error) {
+	stateDir := config.stateDir()
 
-		os.Stdout = devNull
-		os.Stderr = devNull
+	// 由 start 命令启动的后台进程默认将日志写入状态目录
+	logConfig := config.Log
+	if isBackgroundProcess() && logConfig.File == "" {
+		logConfig.File = filepath.Join(stateDir, "tsdmtask.log")
+	}
+	logCloser, err := setupLogger(logConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("初始化日志失败: %w", err)
+	}
 
-		// 捕获信号
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	// 先打开状态存储，创建账户时需要读取保存的 cookie
+	stateStore, err = openStateStore(stateDir)
+	if err != nil {
+		logCloser.Close()
+		return nil, nil, fmt.Errorf("打开状态存储失败: %w", err)
+	}
+	stateStore.skipCookies = !config.saveCookies()
 
-		// 创建 context
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	ledger = openLedger(stateDir)
 
-		// 使用 errgroup 管理并发任务
-		group, ctx := errgroup.WithContext(ctx)
+	accounts, err := newAccounts(config)
+	if err != nil {
+		logCloser.Close()
+		return nil, nil, fmt.Errorf("创建账户失败: %w", err)
+	}
+	return accounts, logCloser, nil
+}
 
-		// 创建动态时区
-		location, err := time.LoadLocation("Asia/Shanghai")
-		if err != nil {
-			fmt.Println("无法加载时区:", err)
-			return
+// runOnce 对每个账户依次执行一次指定的任务，等待推送发送完成后返回
+func runOnce(ctx context.Context, accounts []*account, tasks ...func(ctx context.Context, acc *account)) {
+	for _, acc := range accounts {
+		for _, task := range tasks {
+			if ctx.Err() != nil {
+				break
+			}
+			task(ctx, acc)
 		}
+	}
 
-		for _, account := range config.Account {
-			acc := account // 避免闭包陷阱
+	// 保存任务中更新的 cookie，并等待推送发送完成后再退出
+	stateStore.Flush()
+	pushWaitGroup.Wait()
+}
 
-			// --- 签到任务 ---
-			group.Go(func() error {
-				// 在 -d 模式下，先执行一次签到任务
-				checkInResult, err := tsdmCheckIn(acc.Cookie)
-				if err == nil {
-					fmt.Printf("[%s] %s\n", acc.Name, checkInResult)
-					pushCheckInResult(acc.Name, checkInResult, config.Push.BotToken, config.Push.ChatID)
-				} else {
-					// 记录错误日志
-					fmt.Printf("[%s] 签到失败: %v\n", acc.Name, err)
-				}
+// runDaemon 在当前进程中以守护进程模式持续运行定时任务，收到 SIGINT 或 SIGTERM 时退出
+func runDaemon(config *Config, accounts []*account) error {
+	// 锁定 PID 文件，防止重复运行
+	lock, err := acquirePIDLock(config.pidFile())
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	slog.Info("守护进程已启动", "pid", os.Getpid(), "pid_file", lock.path)
+
+	// 捕获信号
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	// 创建 context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 使用 errgroup 管理并发任务
+	group, ctx := errgroup.WithContext(ctx)
+
+	// --- 状态页 ---
+	if config.Listen != "" {
+		group.Go(func() error {
+			// 状态页出错时不影响其他任务
+			if err := newStatusServer(accounts).serve(ctx, config.Listen); err != nil {
+				slog.Error("状态页服务出错", "error", err)
+			}
+			return nil
+		})
+	}
 
-				// 计算下一次运行时间（UTC+8），提前10秒
-				now := time.Now().In(location)
-				nextRun := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location).Add(-10 * time.Second)
-				if now.After(nextRun) {
-					nextRun = nextRun.AddDate(0, 0, 1)
-				}
+	// --- 新帖子检查任务 ---
+	// 所有账户共用一个检查任务，发现新帖子后立即为所有账户抢红包，每个账户的抢红包任务作为补充
+	if watch := config.RedPacket.Watch.withDefaults(); watch.Interval > 0 {
+		group.Go(func() error {
+			watchRedPackets(ctx, accounts, watch)
+			return nil
+		})
+	}
 
-				ticker := time.NewTicker(time.Until(nextRun))
+	// 零点抢签到的策略
+	race := config.CheckInRace.withDefaults()
 
-				defer ticker.Stop()
+	for _, account := range accounts {
+		acc := account // 避免闭包陷阱
 
-				maxRetryTimes := 100              // 最大重试次数
-				retryInterval := 15 * time.Minute // 重试间隔
+		// --- 签到任务 ---
+		group.Go(func() error {
+			// 在 -d 模式下，先执行一次签到任务
+			runCheckIn(ctx, acc)
 
-				for {
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					case <-ticker.C:
-						// 创建一个可取消的 context
-						childCtx, cancel := context.WithCancel(ctx)
-						defer cancel() // 确保 context 最终被取消
-
-						// 并发尝试签到
-						resultChan := make(chan string, 1)
-						errChan := make(chan error, 1)
-
-						for i := 0; i < 101; i++ {
-							go func(index int) {
-								interval := time.Duration(index+1) * 100 * time.Millisecond // 延迟 0.1 秒
-
-								select {
-								case <-childCtx.Done():
-									return
-								case <-time.After(interval):
-									checkInResult, err := tsdmCheckIn(acc.Cookie)
-									if err != nil {
-										// 签到失败，尝试将错误发送到 errChan，如果 childCtx 已被取消，则直接返回
-										select {
-										case errChan <- err:
-										case <-childCtx.Done():
-											return
-										}
-									} else {
-										if strings.Contains(checkInResult, "签到成功") {
-											// 尝试将签到结果发送到 resultChan，如果 childCtx 已被取消，则直接返回
-											select {
-											case resultChan <- checkInResult:
-											case <-childCtx.Done():
-												return
-											}
-											return
-										}
-									}
-									return
-								}
-							}(i)
-						}
+			// 每天在服务器零点抢签到，开始前先检查登录状态并校准服务器时间
+			for midnight := nextMidnight(forumClock.now()); ; midnight = midnight.AddDate(0, 0, 1) {
+				prepareAt := forumClock.localTime(midnight.Add(-*race.StartOffset - checkInPrepareLead))
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Until(prepareAt)):
+				}
+				if !acc.active() {
+					continue
+				}
 
-						select {
-						case checkInResult := <-resultChan:
-							fmt.Printf("[%s] 签到成功: %s\n", acc.Name, checkInResult)
-							pushCheckInResult(acc.Name, checkInResult, config.Push.BotToken, config.Push.ChatID)
-							cancel() // 签到成功，取消 context，通知其他 goroutine 停止执行
-							return nil
-
-						case err := <-errChan:
-							fmt.Printf("[%s] 签到错误: %v\n", acc.Name, err)
-							// 签到失败，进行重试
-							for i := 0; i < maxRetryTimes; i++ {
-								fmt.Printf("[%s] 开始第 %d 次重试...\n", acc.Name, i+1)
-								checkInResult, err := tsdmCheckIn(acc.Cookie)
-								if err != nil {
-									fmt.Printf("[%s] 重试签到错误: %v\n", acc.Name, err)
-									if i < maxRetryTimes-1 {
-										time.Sleep(retryInterval)
-									}
-								} else {
-									fmt.Printf("[%s] 重试签到成功: %s\n", acc.Name, checkInResult)
-									pushCheckInResult(acc.Name, checkInResult, config.Push.BotToken, config.Push.ChatID)
-									break // 签到成功，退出重试循环
-								}
-							}
-						}
+				if err := prepareCheckIn(ctx, acc); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					if acc.handleLoginError(err) {
+						continue // cookie 已失效，不再签到
 					}
+					acc.logger("checkin").Warn("抢签到准备失败", "error", err)
 				}
-			})
 
-			// --- 打工任务 ---
-			group.Go(func() error {
-				ticker := time.NewTicker(1 * time.Minute) // 设置初始的 ticker 间隔为 1 分钟
-				defer ticker.Stop()
-				for {
-					select {
-					case <-ctx.Done():
-						return nil // 退出循环
-					case <-ticker.C:
-						waitDuration := runWork(acc.Name, acc.Cookie, config.Push.BotToken, config.Push.ChatID)
-						if waitDuration == 0 {
-							waitDuration = 1 * time.Minute // 设置最小等待时间
-						}
-						ticker.Reset(waitDuration) // 重置 ticker 的间隔时间
+				checkInResult, err := raceCheckIn(ctx, acc, race, midnight)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err == nil {
+					acc.logger("checkin").Info(checkInResult.String(), "status", checkInResult.Status, "rank", checkInResult.Rank, "coins", checkInResult.Coins)
+					pushCheckInResult(acc, checkInResult)
+					continue
+				}
+
+				acc.logger("checkin").Error("抢签到失败", "error", err)
+				if acc.handleLoginError(err) {
+					continue // cookie 已失效，不再重试
+				}
+				// 签到失败，按签到重试策略持续重试，除 cookie 失效外的错误都重试
+				acc.logger("checkin").Info("开始重试签到")
+				checkInResult, err = retry(ctx, checkInRetryPolicy, func(err error) bool {
+					return !errors.Is(err, ErrNotLoggedIn)
+				}, acc.logger("checkin"), func(ctx context.Context) (CheckInResult, error) {
+					return retryTask(ctx, acc, tsdmCheckIn)
+				})
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				observeCheckIn(acc.Name, checkInResult, err)
+				if err != nil {
+					acc.logger("checkin").Error("重试签到失败", "error", err)
+					pushCheckInFailure(acc, err) // cookie 失效或重试次数用尽，推送签到失败信息
+				} else {
+					acc.logger("checkin").Info(checkInResult.String(), "status", checkInResult.Status, "rank", checkInResult.Rank, "coins", checkInResult.Coins)
+					pushCheckInResult(acc, checkInResult)
+				}
+			}
+		})
+
+		// --- 打工任务 ---
+		group.Go(func() error {
+			// 从记录的下次打工时间开始调度，没有记录或已过期时立即打工
+			initialWait := time.Until(stateStore.NextWork(acc.Name))
+			if initialWait <= 0 {
+				initialWait = time.Millisecond
+			} else {
+				acc.logger("work").Info("下次打工时间", "next_work", stateStore.NextWork(acc.Name).In(forumLocation).Format(time.DateTime))
+			}
+
+			ticker := time.NewTicker(initialWait)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil // 退出循环
+				case <-ticker.C:
+					waitDuration := runWork(ctx, acc)
+					if waitDuration == 0 {
+						waitDuration = 1 * time.Minute // 设置最小等待时间
 					}
+					ticker.Reset(waitDuration) // 重置 ticker 的间隔时间
 				}
-			})
+			}
+		})
+
+		// --- 抢红包任务 ---
+		group.Go(func() error {
+			ticker := time.NewTicker(5 * time.Minute)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					checkPosts(ctx, acc)
+				}
+			}
+		})
 
-			// --- 抢红包任务 ---
+		// --- 重新登录任务 ---
+		// 自动登录被论坛拒绝后定期再次尝试，登录成功后恢复该账户的任务
+		if acc.credentials != nil {
 			group.Go(func() error {
-				ticker := time.NewTicker(5 * time.Minute)
+				ticker := time.NewTicker(reloginInterval)
 				defer ticker.Stop()
 
 				for {
@@ -706,44 +941,111 @@ func run(config *Config, daemonMode bool) {
 					case <-ctx.Done():
 						return nil
 					case <-ticker.C:
-						checkPosts(acc.Name, acc.Cookie, config.Push.BotToken, config.Push.ChatID)
+						if acc.active() {
+							continue
+						}
+						if err := acc.relogin(ctx); err != nil && ctx.Err() == nil {
+							acc.logger("login").Warn("重新登录失败", "error", err)
+						}
 					}
 				}
 			})
-
 		}
 
-		// 等待信号并取消 context
-		go func() {
-			<-sigs
-			cancel()
-		}()
+		// --- 每日汇总任务 ---
+		group.Go(func() error {
+			// 每天服务器时间 23:50 (UTC+8) 推送汇总，避开零点的签到任务
+			now := forumClock.now().In(forumLocation)
+			nextRun := time.Date(now.Year(), now.Month(), now.Day(), 23, 50, 0, 0, forumLocation)
+			if now.After(nextRun) {
+				nextRun = nextRun.AddDate(0, 0, 1)
+			}
 
-		// 等待所有任务完成
-		if err := group.Wait(); err != nil && err != context.Canceled {
-			fmt.Println("并发任务出错:", err)
-		}
+			// 推送后直接推迟一天，不根据估算的服务器时间重新计算，避免偏差变化时同一天推送两次
+			for ; ; nextRun = nextRun.AddDate(0, 0, 1) {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Until(forumClock.localTime(nextRun))):
+					pushDailySummary(acc)
+				}
+			}
+		})
 
-	} else {
-		// 非守护进程模式
-		for _, account := range config.Account {
-			runCheckIn(account.Name, account.Cookie, config.Push.BotToken, config.Push.ChatID)
-			runWork(account.Name, account.Cookie, config.Push.BotToken, config.Push.ChatID)
-			checkPosts(account.Name, account.Cookie, config.Push.BotToken, config.Push.ChatID)
-		}
 	}
+
+	// 等待信号并取消 context
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	// 等待所有任务完成
+	if err := group.Wait(); err != nil && err != context.Canceled {
+		slog.Error("并发任务出错", "error", err)
+	}
+	stateStore.Flush()
+	return nil
 }
 
 func main() {
+	var accountNames accountFlag
 	configPath := flag.String("c", "config.yaml", "配置文件路径")
-	daemonMode := flag.Bool("d", false, "是否以后台守护进程方式运行")
+	daemonMode := flag.Bool("d", false, "以守护进程模式运行，不带 -f 时等同于 start 命令")
+	foreground := flag.Bool("f", false, "与 -d 一起使用时等同于 run 命令")
+	flag.Var(&accountNames, "account", "只处理指定名称的账户，可重复指定")
+	period := flag.String("period", "day", "report 命令的统计周期，可选 day、week 或 month")
+	flag.Usage = usage
 	flag.Parse()
 
+	commandName := flag.Arg(0)
+	if commandName == "" && *daemonMode {
+		commandName = "start"
+		if *foreground {
+			commandName = "run"
+		}
+	}
+
+	command, ok := findCommand(commandName)
+	if commandName != "" {
+		if !ok {
+			fmt.Fprintf(os.Stderr, "未知命令: %s\n", commandName)
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		// 子命令之后同样可以指定 -c 与 --account
+		commandFlags := flag.NewFlagSet(commandName, flag.ExitOnError)
+		commandFlags.StringVar(configPath, "c", *configPath, "配置文件路径")
+		commandFlags.Var(&accountNames, "account", "只处理指定名称的账户，可重复指定")
+		commandFlags.StringVar(period, "period", *period, "report 命令的统计周期，可选 day、week 或 month")
+		commandFlags.Parse(flag.Args()[min(1, flag.NArg()):])
+	}
+
 	config, err := loadConfig(*configPath)
 	if err != nil {
-		fmt.Println("加载配置文件失败:", err)
-		return
+		slog.Error("加载配置文件失败", "error", err)
+		os.Exit(1)
+	}
+	setupMirrors(config)
+	setupRetry(config)
+	setupRateLimit(config)
+	setupRedPacket(config)
+
+	if err := selectAccounts(config, accountNames); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	run(config, *daemonMode)
+	opts := &cliOptions{config: config, configPath: *configPath, accounts: accountNames, period: *period}
+	if ok {
+		err = command.run(opts)
+	} else {
+		// 未指定命令时执行一次所有任务，单次运行只包含本次的结果，不推送每日汇总
+		err = runTasks(opts, runCheckIn, func(ctx context.Context, acc *account) { runWork(ctx, acc) }, checkPosts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
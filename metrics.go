@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// httpRequestsTotal 统计论坛请求次数
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdm_http_requests_total",
+		Help: "论坛请求次数，status 为 HTTP 状态码，请求失败时为 error",
+	}, []string{"endpoint", "status"})
+
+	// httpRequestDuration 统计论坛请求耗时
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tsdm_http_request_duration_seconds",
+		Help:    "论坛请求耗时",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"endpoint", "status"})
+
+	// checkInTotal 统计签到结果
+	checkInTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdm_checkin_total",
+		Help: "签到次数，outcome 为 success、already 或 failure",
+	}, []string{"account", "outcome"})
+
+	// lastCheckInTimestamp 记录最后一次签到成功或确认已签到的时间
+	lastCheckInTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tsdm_last_checkin_timestamp_seconds",
+		Help: "最后一次签到成功或确认今日已签到的 Unix 时间",
+	}, []string{"account"})
+
+	// workTotal 统计打工结果
+	workTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdm_work_total",
+		Help: "打工次数，outcome 为 success、waiting 或 failure",
+	}, []string{"account", "outcome"})
+
+	// redPacketTotal 统计抢红包结果
+	redPacketTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdm_redpacket_total",
+		Help: "抢红包次数，outcome 为红包结果状态，请求失败时为 failure",
+	}, []string{"account", "outcome"})
+
+	// redPacketCoinsTotal 统计红包获得的天使币
+	redPacketCoinsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsdm_redpacket_coins_total",
+		Help: "红包获得的天使币",
+	}, []string{"account"})
+
+	// clockOffset 记录估算的论坛服务器时间偏差
+	clockOffset = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tsdm_clock_offset_seconds",
+		Help: "论坛服务器时间减去本地时间的估算值",
+	})
+
+	// clockUncertainty 记录服务器时间偏差的误差范围
+	clockUncertainty = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tsdm_clock_offset_uncertainty_seconds",
+		Help: "论坛服务器时间偏差的误差范围",
+	})
+
+	// angelCoins 记录最近一次获取到的天使币数量
+	angelCoins = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tsdm_angel_coins",
+		Help: "最近一次获取到的天使币数量",
+	}, []string{"account"})
+)
+
+// observeRequest 记录一次论坛请求，statusCode 为 0 表示请求失败
+func observeRequest(requestURL string, statusCode int, start time.Time) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	endpoint := endpointLabel(requestURL)
+	httpRequestsTotal.WithLabelValues(endpoint, status).Inc()
+	httpRequestDuration.WithLabelValues(endpoint, status).Observe(time.Since(start).Seconds())
+}
+
+// endpointLabel 返回请求地址对应的接口名称，只保留脚本名与 mod、id 参数，避免 tid 等参数产生过多的标签值
+func endpointLabel(requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "unknown"
+	}
+
+	endpoint := strings.TrimPrefix(u.Path, "/")
+	if endpoint == "" {
+		endpoint = "index"
+	}
+
+	query := u.Query()
+	for _, key := range []string{"mod", "id"} {
+		if value := query.Get(key); value != "" {
+			endpoint += ":" + value
+		}
+	}
+	return endpoint
+}
+
+// observeCheckIn 记录签到结果，程序退出或抢签到中断的请求不记录
+func observeCheckIn(accountName string, result CheckInResult, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		checkInTotal.WithLabelValues(accountName, "failure").Inc()
+		return
+	}
+
+	checkInTotal.WithLabelValues(accountName, string(result.Status)).Inc()
+	lastCheckInTimestamp.WithLabelValues(accountName).SetToCurrentTime()
+}
+
+// observeWork 记录打工结果，程序退出中断的请求不记录
+func observeWork(accountName string, result WorkResult, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		workTotal.WithLabelValues(accountName, "failure").Inc()
+		return
+	}
+	workTotal.WithLabelValues(accountName, string(result.Status)).Inc()
+}
+
+// observeRedPacket 记录抢红包结果
+func observeRedPacket(accountName string, result RedPacketResult, err error) {
+	if err != nil {
+		redPacketTotal.WithLabelValues(accountName, "failure").Inc()
+		return
+	}
+
+	redPacketTotal.WithLabelValues(accountName, string(result.Status)).Inc()
+	if result.Coins > 0 {
+		redPacketCoinsTotal.WithLabelValues(accountName).Add(float64(result.Coins))
+	}
+}
+
+// observeScore 记录天使币数量，无法解析时忽略
+func observeScore(accountName string, score string) {
+	if coins, err := strconv.ParseFloat(score, 64); err == nil {
+		angelCoins.WithLabelValues(accountName).Set(coins)
+	}
+}
+
+// observeClock 记录服务器时间偏差
+func observeClock(status ClockStatus) {
+	clockOffset.Set(status.Offset.Seconds())
+	clockUncertainty.Set(status.Uncertainty.Seconds())
+}
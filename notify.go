@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+// pushTitle 定义推送消息的标题
+const pushTitle = "【天使动漫论坛任务推送】"
+
+// Priority 定义推送消息的优先级
+type Priority string
+
+const (
+	PriorityNormal Priority = "normal" // 普通消息
+	PriorityHigh   Priority = "high"   // 需要尽快处理的消息，支持的推送渠道会以更醒目的方式提醒
+)
+
+// Message 定义推送消息
+type Message struct {
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Priority Priority `json:"priority"`
+}
+
+// Notifier 定义推送渠道接口
+type Notifier interface {
+	// Name 返回推送渠道名称，用于日志输出
+	Name() string
+	// Send 发送一条推送消息
+	Send(msg Message) error
+}
+
+// notifierFactory 根据配置选项创建推送渠道
+type notifierFactory func(options *yaml.Node) (Notifier, error)
+
+// notifierRegistry 定义推送渠道注册表，key 为配置中的 type
+var notifierRegistry = map[string]notifierFactory{}
+
+// registerNotifier 注册推送渠道
+func registerNotifier(typ string, factory notifierFactory) {
+	notifierRegistry[typ] = factory
+}
+
+func init() {
+	registerNotifier("telegram", newTelegramNotifier)
+	registerNotifier("webhook", newWebhookNotifier)
+	registerNotifier("smtp", newSMTPNotifier)
+	registerNotifier("bark", newBarkNotifier)
+	registerNotifier("serverchan", newServerChanNotifier)
+	registerNotifier("ntfy", newNtfyNotifier)
+}
+
+// EventType 定义推送事件类型
+type EventType string
+
+const (
+	EventCheckInSuccess EventType = "checkin_success" // 签到成功
+	EventCheckInAlready EventType = "checkin_already" // 今日已签到
+	EventCheckInFailure EventType = "checkin_failure" // 签到失败
+	EventWorkSuccess    EventType = "work_success"    // 打工成功
+	EventWorkFailure    EventType = "work_failure"    // 打工失败
+	EventRedPacket      EventType = "redpacket"       // 抢到红包
+	EventCookieExpired  EventType = "cookie_expired"  // Cookie 失效
+	EventDailySummary   EventType = "daily_summary"   // 每日汇总
+)
+
+// allEvents 定义所有推送事件类型
+var allEvents = []EventType{
+	EventCheckInSuccess,
+	EventCheckInAlready,
+	EventCheckInFailure,
+	EventWorkSuccess,
+	EventWorkFailure,
+	EventRedPacket,
+	EventCookieExpired,
+	EventDailySummary,
+}
+
+// eventPriority 返回事件对应的消息优先级
+func eventPriority(event EventType) Priority {
+	if event == EventCookieExpired {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// defaultEvents 定义未配置 events 时推送的事件类型
+var defaultEvents = []EventType{
+	EventCheckInSuccess,
+	EventWorkSuccess,
+	EventRedPacket,
+	EventCookieExpired,
+}
+
+// PushConfig 定义单个推送目标的配置，除 type 与 events 外的字段由对应的推送渠道解析
+type PushConfig struct {
+	Type    string
+	Events  []string // 接收的事件类型，为 all 时接收所有事件，为空时使用 defaultEvents
+	Options yaml.Node
+}
+
+// UnmarshalYAML 解析推送目标配置
+func (p *PushConfig) UnmarshalYAML(node *yaml.Node) error {
+	var head struct {
+		Type   string   `yaml:"type"`
+		Events []string `yaml:"events"`
+	}
+	if err := node.Decode(&head); err != nil {
+		return err
+	}
+
+	p.Type = head.Type
+	p.Events = head.Events
+	p.Options = *node
+	return nil
+}
+
+// eventSet 解析推送目标接收的事件类型
+func (p *PushConfig) eventSet() (map[EventType]bool, error) {
+	events := defaultEvents
+	if len(p.Events) > 0 {
+		events = nil
+		for _, name := range p.Events {
+			event := EventType(name)
+			if name == "all" {
+				events = append(events, allEvents...)
+				continue
+			}
+			if !slices.Contains(allEvents, event) {
+				return nil, fmt.Errorf("事件类型 %q 不受支持", name)
+			}
+			events = append(events, event)
+		}
+	}
+
+	set := make(map[EventType]bool, len(events))
+	for _, event := range events {
+		set[event] = true
+	}
+	return set, nil
+}
+
+// PushConfigs 定义推送目标列表
+type PushConfigs []PushConfig
+
+// UnmarshalYAML 解析推送目标列表，兼容旧版的 push: {bot_token, chat_id} 写法
+func (p *PushConfigs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var single PushConfig
+		if err := node.Decode(&single); err != nil {
+			return err
+		}
+		if single.Type == "" {
+			single.Type = "telegram"
+		}
+		*p = PushConfigs{single}
+		return nil
+	}
+
+	var list []PushConfig
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// buildNotifiers 根据推送目标列表创建推送渠道
+func buildNotifiers(configs PushConfigs) (Notifiers, error) {
+	var notifiers Notifiers
+	for i, config := range configs {
+		factory, ok := notifierRegistry[config.Type]
+		if !ok {
+			return nil, fmt.Errorf("第 %d 个推送目标的类型 %q 不受支持，可选类型: %s", i+1, config.Type, strings.Join(notifierTypes(), ", "))
+		}
+
+		notifier, err := factory(&config.Options)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个推送目标 (%s) 配置错误: %w", i+1, config.Type, err)
+		}
+
+		events, err := config.eventSet()
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个推送目标 (%s) 配置错误: %w", i+1, config.Type, err)
+		}
+		notifiers = append(notifiers, routedNotifier{Notifier: notifier, events: events})
+	}
+	return notifiers, nil
+}
+
+// notifierTypes 返回已注册的推送渠道类型
+func notifierTypes() []string {
+	types := make([]string, 0, len(notifierRegistry))
+	for typ := range notifierRegistry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// routedNotifier 定义只接收指定事件的推送渠道
+type routedNotifier struct {
+	Notifier
+	events map[EventType]bool
+}
+
+// Notifiers 定义推送渠道列表
+type Notifiers []routedNotifier
+
+// pushWaitGroup 记录尚未发送完成的推送，程序退出前需要等待
+var pushWaitGroup sync.WaitGroup
+
+// Push 异步将消息发送到所有接收该事件的推送渠道
+func (ns Notifiers) Push(event EventType, data string) {
+	msg := Message{Title: pushTitle, Text: data, Priority: eventPriority(event)}
+	for _, notifier := range ns {
+		if !notifier.events[event] {
+			continue
+		}
+
+		pushWaitGroup.Add(1)
+		go func(notifier routedNotifier) {
+			defer pushWaitGroup.Done()
+			if err := notifier.Send(msg); err != nil {
+				slog.Error("推送失败", "notifier", notifier.Name(), "event", event, "error", err)
+			}
+		}(notifier)
+	}
+}
+
+// notifyRequest 发送推送请求，HTTP 状态码不是 2xx 时返回错误
+func notifyRequest(method, url string, body []byte, headers map[string]string) ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(url)
+	req.Header.SetMethod(method)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if method != "GET" {
+		req.SetBody(body)
+	}
+
+	if err := httpClient.DoTimeout(req, resp, 30*time.Second); err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	respBody := append([]byte(nil), resp.Body()...)
+	if code := resp.StatusCode(); code < 200 || code >= 300 {
+		return respBody, fmt.Errorf("HTTP 状态码 %d: %s", code, respBody)
+	}
+	return respBody, nil
+}
+
+// postJSON 以 JSON 格式发送 POST 推送请求
+func postJSON(url string, payload any, headers map[string]string) ([]byte, error) {
+	return sendJSON("POST", url, payload, headers)
+}
+
+// sendJSON 使用指定的方法以 JSON 格式发送推送请求
+func sendJSON(method, url string, payload any, headers map[string]string) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	allHeaders := map[string]string{"Content-Type": "application/json; charset=utf-8"}
+	for k, v := range headers {
+		allHeaders[k] = v
+	}
+	return notifyRequest(method, url, body, allHeaders)
+}
+
+// telegramNotifier 通过 Telegram 机器人推送
+type telegramNotifier struct {
+	BotToken string `yaml:"bot_token"`
+	ChatID   string `yaml:"chat_id"`
+	APIURL   string `yaml:"api_url"` // 默认为 https://api.telegram.org
+}
+
+// newTelegramNotifier 创建 Telegram 推送渠道
+func newTelegramNotifier(options *yaml.Node) (Notifier, error) {
+	n := &telegramNotifier{APIURL: "https://api.telegram.org"}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.BotToken == "" || n.ChatID == "" {
+		return nil, fmt.Errorf("缺少 bot_token 或 chat_id")
+	}
+	n.APIURL = strings.TrimRight(n.APIURL, "/")
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *telegramNotifier) Name() string { return "telegram" }
+
+// Send 发送 Telegram 推送消息
+func (n *telegramNotifier) Send(msg Message) error {
+	formData := url.Values{
+		"chat_id": {n.ChatID},
+		"text":    {msg.Title + "\r\n" + msg.Text},
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	}
+
+	_, err := notifyRequest("POST", n.APIURL+"/bot"+n.BotToken+"/sendMessage", []byte(formData.Encode()), headers)
+	if err != nil {
+		return fmt.Errorf("telegram 推送失败: %w", err)
+	}
+	return nil
+}
+
+// webhookNotifier 通过通用 Webhook 推送
+type webhookNotifier struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`  // 默认为 POST，消息通过请求体发送，因此不支持 GET
+	Headers map[string]string `yaml:"headers"` // 额外的请求头
+	Body    string            `yaml:"body"`    // 请求体模板，可使用 {{.Title}}、{{.Text}} 与 {{.Priority}}，为空时发送 JSON
+
+	body *template.Template
+}
+
+// newWebhookNotifier 创建 Webhook 推送渠道
+func newWebhookNotifier(options *yaml.Node) (Notifier, error) {
+	n := &webhookNotifier{Method: "POST"}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.URL == "" {
+		return nil, fmt.Errorf("缺少 url")
+	}
+	n.Method = strings.ToUpper(n.Method)
+	if n.Method == "GET" || n.Method == "HEAD" {
+		return nil, fmt.Errorf("method 不能为 %s，消息需要通过请求体发送", n.Method)
+	}
+
+	if n.Body != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			// json 将字符串转义为 JSON 字符串字面量
+			"json": func(s string) (string, error) {
+				data, err := json.Marshal(s)
+				return string(data), err
+			},
+		}).Parse(n.Body)
+		if err != nil {
+			return nil, fmt.Errorf("解析 body 模板失败: %w", err)
+		}
+		n.body = tmpl
+	}
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+// Send 发送 Webhook 推送消息
+func (n *webhookNotifier) Send(msg Message) error {
+	var err error
+	if n.body == nil {
+		_, err = sendJSON(n.Method, n.URL, msg, n.Headers)
+	} else {
+		var buf bytes.Buffer
+		if err = n.body.Execute(&buf, msg); err != nil {
+			return fmt.Errorf("webhook 渲染 body 失败: %w", err)
+		}
+		_, err = notifyRequest(n.Method, n.URL, buf.Bytes(), n.Headers)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook 推送失败: %w", err)
+	}
+	return nil
+}
+
+// smtpNotifier 通过 SMTP 邮件推送
+type smtpNotifier struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"` // 默认为 25，SSL 模式下默认为 465
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"` // 默认为 username
+	To       []string `yaml:"to"`
+	SSL      bool     `yaml:"ssl"` // 是否使用 SSL 直接连接，否则在服务器支持时使用 STARTTLS
+}
+
+// newSMTPNotifier 创建 SMTP 推送渠道
+func newSMTPNotifier(options *yaml.Node) (Notifier, error) {
+	n := &smtpNotifier{}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.Host == "" || len(n.To) == 0 {
+		return nil, fmt.Errorf("缺少 host 或 to")
+	}
+	if n.Port == 0 {
+		n.Port = 25
+		if n.SSL {
+			n.Port = 465
+		}
+	}
+	if n.From == "" {
+		n.From = n.Username
+	}
+	if n.From == "" {
+		return nil, fmt.Errorf("缺少 from")
+	}
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *smtpNotifier) Name() string { return "smtp" }
+
+// Send 发送邮件推送消息
+func (n *smtpNotifier) Send(msg Message) error {
+	if err := n.send(msg); err != nil {
+		return fmt.Errorf("smtp 推送失败: %w", err)
+	}
+	return nil
+}
+
+// send 连接 SMTP 服务器并发送邮件
+func (n *smtpNotifier) send(msg Message) error {
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if n.SSL {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: n.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !n.SSL {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.buildMail(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMail 构造邮件内容
+func (n *smtpNotifier) buildMail(msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.Priority == PriorityHigh {
+		buf.WriteString("X-Priority: 1\r\n")
+		buf.WriteString("Importance: high\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// barkNotifier 通过 Bark 推送
+type barkNotifier struct {
+	Server string `yaml:"server"` // 默认为 https://api.day.app
+	Key    string `yaml:"key"`
+	Group  string `yaml:"group"`
+}
+
+// newBarkNotifier 创建 Bark 推送渠道
+func newBarkNotifier(options *yaml.Node) (Notifier, error) {
+	n := &barkNotifier{Server: "https://api.day.app"}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.Key == "" {
+		return nil, fmt.Errorf("缺少 key")
+	}
+	n.Server = strings.TrimRight(n.Server, "/")
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *barkNotifier) Name() string { return "bark" }
+
+// Send 发送 Bark 推送消息
+func (n *barkNotifier) Send(msg Message) error {
+	payload := map[string]string{
+		"device_key": n.Key,
+		"title":      msg.Title,
+		"body":       msg.Text,
+	}
+	if n.Group != "" {
+		payload["group"] = n.Group
+	}
+	if msg.Priority == PriorityHigh {
+		payload["level"] = "timeSensitive" // 可在专注模式下提醒
+	}
+
+	if _, err := postJSON(n.Server+"/push", payload, nil); err != nil {
+		return fmt.Errorf("bark 推送失败: %w", err)
+	}
+	return nil
+}
+
+// serverChanNotifier 通过 Server 酱推送
+type serverChanNotifier struct {
+	SendKey string `yaml:"sendkey"`
+	APIURL  string `yaml:"api_url"` // 默认为 https://sctapi.ftqq.com
+}
+
+// newServerChanNotifier 创建 Server 酱推送渠道
+func newServerChanNotifier(options *yaml.Node) (Notifier, error) {
+	n := &serverChanNotifier{APIURL: "https://sctapi.ftqq.com"}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.SendKey == "" {
+		return nil, fmt.Errorf("缺少 sendkey")
+	}
+	n.APIURL = strings.TrimRight(n.APIURL, "/")
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *serverChanNotifier) Name() string { return "serverchan" }
+
+// Send 发送 Server 酱推送消息
+func (n *serverChanNotifier) Send(msg Message) error {
+	formData := url.Values{
+		"title": {msg.Title},
+		"desp":  {msg.Text},
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	}
+
+	respData, err := notifyRequest("POST", n.APIURL+"/"+n.SendKey+".send", []byte(formData.Encode()), headers)
+	if err != nil {
+		return fmt.Errorf("serverchan 推送失败: %w", err)
+	}
+
+	// Server 酱在请求失败时仍返回 200，需要检查返回的 code
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(respData, &result) == nil && result.Code != 0 {
+		return fmt.Errorf("serverchan 推送失败: %s", result.Message)
+	}
+	return nil
+}
+
+// ntfyNotifier 通过 ntfy 推送
+type ntfyNotifier struct {
+	Server string `yaml:"server"` // 默认为 https://ntfy.sh
+	Topic  string `yaml:"topic"`
+	Token  string `yaml:"token"`
+}
+
+// newNtfyNotifier 创建 ntfy 推送渠道
+func newNtfyNotifier(options *yaml.Node) (Notifier, error) {
+	n := &ntfyNotifier{Server: "https://ntfy.sh"}
+	if err := options.Decode(n); err != nil {
+		return nil, err
+	}
+	if n.Topic == "" {
+		return nil, fmt.Errorf("缺少 topic")
+	}
+	n.Server = strings.TrimRight(n.Server, "/")
+	return n, nil
+}
+
+// Name 返回推送渠道名称
+func (n *ntfyNotifier) Name() string { return "ntfy" }
+
+// Send 发送 ntfy 推送消息
+func (n *ntfyNotifier) Send(msg Message) error {
+	payload := map[string]any{
+		"topic":   n.Topic,
+		"title":   msg.Title,
+		"message": msg.Text,
+	}
+	if msg.Priority == PriorityHigh {
+		payload["priority"] = 5 // 对应 ntfy 的 urgent 优先级
+	}
+
+	var headers map[string]string
+	if n.Token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.Token}
+	}
+
+	// 以 JSON 格式发布到服务器根路径，避免标题中的中文无法放入请求头
+	if _, err := postJSON(n.Server, payload, headers); err != nil {
+		return fmt.Errorf("ntfy 推送失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testMessage 定义测试使用的推送消息
+var testMessage = Message{Title: pushTitle, Text: "签到成功", Priority: PriorityHigh}
+
+// capturedRequest 定义测试服务器收到的请求
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newCaptureServer 创建记录收到的请求并返回 response 的测试服务器
+func newCaptureServer(t *testing.T, response string) (*httptest.Server, <-chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: body}
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// newTestNotifier 根据 YAML 配置创建推送渠道
+func newTestNotifier(t *testing.T, factory notifierFactory, options string) Notifier {
+	t.Helper()
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(options), &node); err != nil {
+		t.Fatalf("解析配置失败: %v", err)
+	}
+	notifier, err := factory(node.Content[0])
+	if err != nil {
+		t.Fatalf("创建推送渠道失败: %v", err)
+	}
+	return notifier
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	server, requests := newCaptureServer(t, `{"ok":true}`)
+	notifier := newTestNotifier(t, newTelegramNotifier, "bot_token: token\nchat_id: '42'\napi_url: "+server.URL+"/")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	if req.Method != "POST" || req.Path != "/bottoken/sendMessage" {
+		t.Errorf("请求为 %s %s", req.Method, req.Path)
+	}
+	form, _ := url.ParseQuery(string(req.Body))
+	if form.Get("chat_id") != "42" || !strings.Contains(form.Get("text"), testMessage.Text) {
+		t.Errorf("请求体为 %s", req.Body)
+	}
+}
+
+func TestWebhookNotifierJSON(t *testing.T) {
+	server, requests := newCaptureServer(t, "ok")
+	notifier := newTestNotifier(t, newWebhookNotifier, "url: "+server.URL+"/hook\nmethod: put\nheaders:\n  X-Token: secret")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	if req.Method != "PUT" || req.Path != "/hook" {
+		t.Errorf("请求为 %s %s", req.Method, req.Path)
+	}
+	if req.Header.Get("X-Token") != "secret" {
+		t.Errorf("缺少自定义请求头")
+	}
+	var msg Message
+	if err := json.Unmarshal(req.Body, &msg); err != nil || msg != testMessage {
+		t.Errorf("请求体为 %s", req.Body)
+	}
+}
+
+func TestWebhookNotifierTemplate(t *testing.T) {
+	server, requests := newCaptureServer(t, "ok")
+	notifier := newTestNotifier(t, newWebhookNotifier, "url: "+server.URL+"\nbody: '{\"content\": {{json .Text}}}'")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	if req.Method != "POST" || string(req.Body) != `{"content": "签到成功"}` {
+		t.Errorf("请求为 %s %s", req.Method, req.Body)
+	}
+}
+
+func TestWebhookNotifierRejectsGet(t *testing.T) {
+	var node yaml.Node
+	yaml.Unmarshal([]byte("url: http://127.0.0.1/\nmethod: get"), &node)
+	if _, err := newWebhookNotifier(node.Content[0]); err == nil {
+		t.Error("method 为 GET 时应返回错误")
+	}
+}
+
+func TestWebhookNotifierHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	notifier := newTestNotifier(t, newWebhookNotifier, "url: "+server.URL)
+
+	if err := notifier.Send(testMessage); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("错误为 %v", err)
+	}
+}
+
+func TestBarkNotifier(t *testing.T) {
+	server, requests := newCaptureServer(t, `{"code":200}`)
+	notifier := newTestNotifier(t, newBarkNotifier, "server: "+server.URL+"\nkey: device\ngroup: tsdm")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	var payload map[string]string
+	json.Unmarshal(req.Body, &payload)
+	if req.Path != "/push" || payload["device_key"] != "device" || payload["group"] != "tsdm" || payload["level"] != "timeSensitive" {
+		t.Errorf("请求为 %s %s", req.Path, req.Body)
+	}
+}
+
+func TestServerChanNotifier(t *testing.T) {
+	server, requests := newCaptureServer(t, `{"code":0,"message":""}`)
+	notifier := newTestNotifier(t, newServerChanNotifier, "sendkey: SCT123\napi_url: "+server.URL)
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	form, _ := url.ParseQuery(string(req.Body))
+	if req.Path != "/SCT123.send" || form.Get("desp") != testMessage.Text {
+		t.Errorf("请求为 %s %s", req.Path, req.Body)
+	}
+}
+
+func TestServerChanNotifierError(t *testing.T) {
+	server, _ := newCaptureServer(t, `{"code":40001,"message":"bad sendkey"}`)
+	notifier := newTestNotifier(t, newServerChanNotifier, "sendkey: SCT123\napi_url: "+server.URL)
+
+	if err := notifier.Send(testMessage); err == nil || !strings.Contains(err.Error(), "bad sendkey") {
+		t.Errorf("错误为 %v", err)
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	server, requests := newCaptureServer(t, `{"id":"1"}`)
+	notifier := newTestNotifier(t, newNtfyNotifier, "server: "+server.URL+"\ntopic: tsdm\ntoken: tk")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	req := <-requests
+	var payload map[string]any
+	json.Unmarshal(req.Body, &payload)
+	if req.Header.Get("Authorization") != "Bearer tk" || payload["topic"] != "tsdm" || payload["priority"] != float64(5) {
+		t.Errorf("请求为 %v %s", req.Header, req.Body)
+	}
+}
+
+// serveSMTP 在 listener 上处理一个 SMTP 会话，返回收到的邮件内容，不支持 STARTTLS 与认证
+func serveSMTP(listener net.Listener, mail chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	io.WriteString(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			io.WriteString(conn, "250-localhost\r\n250 8BITMIME\r\n")
+		case strings.HasPrefix(command, "DATA"):
+			io.WriteString(conn, "354 go ahead\r\n")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mail <- data.String()
+			io.WriteString(conn, "250 OK\r\n")
+		case strings.HasPrefix(command, "QUIT"):
+			io.WriteString(conn, "221 bye\r\n")
+			return
+		default:
+			io.WriteString(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	mail := make(chan string, 1)
+	go serveSMTP(listener, mail)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	notifier := newTestNotifier(t, newSMTPNotifier, "host: 127.0.0.1\nport: "+strconv.Itoa(port)+"\nfrom: bot@example.com\nto: [user@example.com]")
+
+	if err := notifier.Send(testMessage); err != nil {
+		t.Fatalf("推送失败: %v", err)
+	}
+	data := <-mail
+	for _, want := range []string{"From: bot@example.com", "To: user@example.com", "X-Priority: 1", testMessage.Text} {
+		if !strings.Contains(data, want) {
+			t.Errorf("邮件中缺少 %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit 定义令牌桶限速，rate 为每秒请求数，burst 为允许的突发请求数
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`  // 每秒请求数，为负数时不限速
+	Burst int     `yaml:"burst"` // 令牌桶容量，即短时间内最多连续发送的请求数
+}
+
+// RateLimitConfig 定义论坛请求的限速配置
+type RateLimitConfig struct {
+	Global  RateLimit `yaml:"global"`  // 所有账户共享的限速
+	Account RateLimit `yaml:"account"` // 每个账户单独的限速
+	CheckIn RateLimit `yaml:"checkin"` // 零点签到期间每个账户的限速，代替账户的限速，仍受全局限速限制
+}
+
+// defaultRateLimit 定义默认的限速配置
+var defaultRateLimit = RateLimitConfig{
+	Global:  RateLimit{Rate: 10, Burst: 20},
+	Account: RateLimit{Rate: 2, Burst: 5},
+	CheckIn: RateLimit{Rate: 10, Burst: 10},
+}
+
+// forumLimiter 定义所有账户共享的论坛请求限速器
+var forumLimiter = defaultRateLimit.Global.limiter()
+
+// rateLimit 定义当前使用的限速配置，创建账户时用于生成账户的限速器
+var rateLimit = defaultRateLimit
+
+// setupRateLimit 根据配置初始化限速器，未设置的字段使用默认值
+func setupRateLimit(config *Config) {
+	rateLimit = RateLimitConfig{
+		Global:  config.RateLimit.Global.withDefaults(defaultRateLimit.Global),
+		Account: config.RateLimit.Account.withDefaults(defaultRateLimit.Account),
+		CheckIn: config.RateLimit.CheckIn.withDefaults(defaultRateLimit.CheckIn),
+	}
+	forumLimiter = rateLimit.Global.limiter()
+}
+
+// withDefaults 返回使用 defaults 填充未设置字段后的限速配置
+func (l RateLimit) withDefaults(defaults RateLimit) RateLimit {
+	if l.Rate == 0 {
+		l.Rate = defaults.Rate
+	}
+	if l.Burst <= 0 {
+		l.Burst = defaults.Burst
+	}
+	return l
+}
+
+// limiter 创建令牌桶限速器，rate 为负数时不限速
+func (l RateLimit) limiter() *rate.Limiter {
+	if l.Rate < 0 {
+		return rate.NewLimiter(rate.Inf, l.Burst)
+	}
+	return rate.NewLimiter(rate.Limit(l.Rate), l.Burst)
+}
+
+// checkInBurstKey 定义标记零点签到请求的 context key
+type checkInBurstKey struct{}
+
+// withCheckInBurst 返回标记为零点签到的 context，使用该 context 的请求按签到限速发送
+func withCheckInBurst(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checkInBurstKey{}, true)
+}
+
+// rateLimiters 返回发送请求前需要等待的限速器，零点签到的请求使用账户的签到限速器代替账户限速器，
+// 所有请求都受全局限速器限制，避免账户较多时零点的请求超过全局限速
+func rateLimiters(ctx context.Context, session *forumSession) []*rate.Limiter {
+	if burst, _ := ctx.Value(checkInBurstKey{}).(bool); burst {
+		return []*rate.Limiter{forumLimiter, session.checkInLimiter}
+	}
+	return []*rate.Limiter{forumLimiter, session.limiter}
+}
+
+// waitRateLimit 等待限速器允许发送请求
+func waitRateLimit(ctx context.Context, session *forumSession) error {
+	for _, limiter := range rateLimiters(ctx, session) {
+		if limiter == nil {
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("等待限速失败: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiters(t *testing.T) {
+	session := &forumSession{
+		limiter:        rate.NewLimiter(2, 5),
+		checkInLimiter: rate.NewLimiter(10, 10),
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []*rate.Limiter
+	}{
+		{"普通请求", context.Background(), []*rate.Limiter{forumLimiter, session.limiter}},
+		{"零点签到", withCheckInBurst(context.Background()), []*rate.Limiter{forumLimiter, session.checkInLimiter}},
+	}
+	for _, tt := range tests {
+		if got := rateLimiters(tt.ctx, session); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: 使用的限速器为 %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWaitRateLimitCheckInKeepsGlobalLimit(t *testing.T) {
+	saved := forumLimiter
+	forumLimiter = rate.NewLimiter(rate.Every(time.Hour), 1) // 全局只允许一个请求
+	t.Cleanup(func() { forumLimiter = saved })
+
+	sessions := []*forumSession{
+		{checkInLimiter: rate.NewLimiter(rate.Inf, 10)},
+		{checkInLimiter: rate.NewLimiter(rate.Inf, 10)},
+	}
+	ctx, cancel := context.WithTimeout(withCheckInBurst(context.Background()), 50*time.Millisecond)
+	defer cancel()
+
+	if err := waitRateLimit(ctx, sessions[0]); err != nil {
+		t.Fatalf("第一个签到请求应立即发送: %v", err)
+	}
+	if err := waitRateLimit(ctx, sessions[1]); err == nil {
+		t.Error("其他账户的签到请求也应受全局限速限制")
+	}
+}
+
+func TestRateLimitWithDefaults(t *testing.T) {
+	defaults := RateLimit{Rate: 10, Burst: 20}
+	tests := []struct {
+		limit RateLimit
+		want  RateLimit
+	}{
+		{RateLimit{}, defaults},
+		{RateLimit{Rate: 5}, RateLimit{Rate: 5, Burst: 20}},
+		{RateLimit{Rate: -1, Burst: 3}, RateLimit{Rate: -1, Burst: 3}},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.withDefaults(defaults); got != tt.want {
+			t.Errorf("withDefaults(%+v) = %+v，应为 %+v", tt.limit, got, tt.want)
+		}
+	}
+	if limiter := (RateLimit{Rate: -1, Burst: 1}).limiter(); limiter.Limit() != rate.Inf {
+		t.Errorf("rate 为负数时不应限速，限速为 %v", limiter.Limit())
+	}
+}
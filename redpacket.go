@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/net/html/charset"
+)
+
+// RedPacketConfig 定义抢红包任务的配置
+type RedPacketConfig struct {
+	Forums      []RedPacketForum `yaml:"forums"`       // 检查红包的版块，默认只检查水区第 1 页
+	Markers     []string         `yaml:"markers"`      // 帖子列表中红包帖的标记，出现在帖子所在行的 HTML 中，默认为 tsdmbet 与 红包
+	MarkersOnly bool             `yaml:"markers_only"` // 只领取带有标记的帖子，不探测其他帖子
+
+	Watch RedPacketWatchConfig `yaml:"watch"` // 守护进程中检查新帖子的配置
+}
+
+// RedPacketForum 定义检查红包的版块
+type RedPacketForum struct {
+	FID   int `yaml:"fid"`   // 版块 ID
+	Pages int `yaml:"pages"` // 检查的页数，默认为 1
+}
+
+// defaultRedPacketForums 定义默认检查的版块
+var defaultRedPacketForums = []RedPacketForum{{FID: 4, Pages: 1}}
+
+// defaultRedPacketMarkers 定义默认的红包帖标记，匹配红包插件的图标、链接以及标题中的“红包”
+var defaultRedPacketMarkers = []string{"tsdmbet", "红包"}
+
+// redPacketForums 定义检查红包的版块
+var redPacketForums = defaultRedPacketForums
+
+// redPacketMarkers 定义帖子列表中红包帖的标记
+var redPacketMarkers = defaultRedPacketMarkers
+
+// redPacketMarkersOnly 定义是否只领取带有标记的帖子
+var redPacketMarkersOnly bool
+
+// setupRedPacket 根据配置初始化检查红包的版块与红包帖的识别方式
+func setupRedPacket(config *Config) {
+	redPacketMarkers = defaultRedPacketMarkers
+	if len(config.RedPacket.Markers) > 0 {
+		redPacketMarkers = config.RedPacket.Markers
+	}
+	redPacketMarkersOnly = config.RedPacket.MarkersOnly
+
+	redPacketForums = defaultRedPacketForums
+	if len(config.RedPacket.Forums) > 0 {
+		redPacketForums = make([]RedPacketForum, 0, len(config.RedPacket.Forums))
+		for _, forum := range config.RedPacket.Forums {
+			redPacketForums = append(redPacketForums, RedPacketForum{FID: forum.FID, Pages: max(forum.Pages, 1)})
+		}
+	}
+}
+
+// check 检查红包配置是否有效
+func (c RedPacketConfig) check() error {
+	for _, forum := range c.Forums {
+		if forum.FID <= 0 {
+			return fmt.Errorf("版块 fid 应为正整数")
+		}
+		if forum.Pages < 0 {
+			return fmt.Errorf("版块 %d 的 pages 不能为负数", forum.FID)
+		}
+	}
+	return c.Watch.check()
+}
+
+// threadListPrefixes 定义帖子列表中置顶帖与普通帖的 tbody id 前缀，id 的其余部分为帖子 ID
+var threadListPrefixes = []string{"stickthread_", "normalthread_"}
+
+// forumThread 定义帖子列表中的帖子
+type forumThread struct {
+	TID    string
+	Title  string
+	Marked bool // 帖子所在行带有红包帖的标记
+}
+
+// forumDisplayPath 返回版块帖子列表指定页的路径
+func forumDisplayPath(fid, page int) string {
+	path := fmt.Sprintf("/forum.php?mod=forumdisplay&fid=%d", fid)
+	if page > 1 {
+		path += fmt.Sprintf("&page=%d", page)
+	}
+	return path
+}
+
+// scanForum 获取帖子列表页面，返回置顶帖与普通帖，path 为帖子列表的路径
+func scanForum(ctx context.Context, acc *account, path string) ([]forumThread, error) {
+	respData, err := forumRequest(ctx, "GET", path, "", nil, acc.session)
+	if err != nil {
+		return nil, fmt.Errorf("获取帖子列表页面失败: %w", err)
+	}
+	if err := checkLoginPage(respData); err != nil {
+		return nil, err
+	}
+
+	// 使用 goquery 解析 HTML 代码
+	contentType := mimetype.Detect(respData).String()
+	reader, err := charset.NewReader(strings.NewReader(string(respData)), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("创建 reader 失败: %w", err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	// 从 tbody 的 id 中提取帖子 ID，不依赖帖子链接的格式 (开启伪静态后链接中没有 tid 参数)
+	var threads []forumThread
+	for _, prefix := range threadListPrefixes {
+		doc.Find("tbody[id^='" + prefix + "']").Each(func(i int, s *goquery.Selection) {
+			id, _ := s.Attr("id")
+			tid := strings.TrimPrefix(id, prefix)
+			if tid == "" || strings.Trim(tid, "0123456789") != "" {
+				acc.logger("redpacket").Debug("帖子 ID 无效", "id", id)
+				return
+			}
+
+			// 在帖子所在行的图标、链接与标题中查找红包帖的标记
+			html, _ := goquery.OuterHtml(s)
+			marked := false
+			for _, marker := range redPacketMarkers {
+				if strings.Contains(html, marker) {
+					marked = true
+					break
+				}
+			}
+			title := strings.TrimSpace(s.Find("a.xst").First().Text())
+			threads = append(threads, forumThread{TID: tid, Title: title, Marked: marked})
+		})
+	}
+	return threads, nil
+}
+
+// collectThreads 依次扫描所有配置的版块，返回按出现顺序去重后的帖子
+func collectThreads(ctx context.Context, acc *account) ([]forumThread, error) {
+	var threads []forumThread
+	index := make(map[string]int)
+	for _, forum := range redPacketForums {
+		for page := 1; page <= forum.Pages; page++ {
+			pageThreads, err := retryTask(ctx, acc, func(ctx context.Context, acc *account) ([]forumThread, error) {
+				return scanForum(ctx, acc, forumDisplayPath(forum.FID, page))
+			})
+			if err != nil {
+				if ctx.Err() != nil || acc.handleLoginError(err) {
+					return nil, err
+				}
+				// 单个版块出错时继续检查其他版块
+				acc.logger("redpacket").Error("获取帖子列表页面失败", "fid", forum.FID, "page", page, "error", err)
+				acc.status.recordError("红包", err)
+				break
+			}
+
+			for _, thread := range pageThreads {
+				if i, ok := index[thread.TID]; ok {
+					threads[i].Marked = threads[i].Marked || thread.Marked
+					continue
+				}
+				index[thread.TID] = len(threads)
+				threads = append(threads, thread)
+			}
+		}
+	}
+	return threads, nil
+}
+
+// redPacketProbes 记录正在探测的帖子，同一帖子同时只由一个账户探测，其他账户等待探测结果
+var redPacketProbes = struct {
+	mu      sync.Mutex
+	pending map[string]chan struct{}
+}{pending: map[string]chan struct{}{}}
+
+// detectRedPacket 判断帖子是否有红包，检测结果保存在状态存储中供所有账户使用。
+// 已有检测结果时直接使用；带有标记的帖子视为有红包；其他帖子由一个账户请求红包接口进行探测，
+// 探测本身就是一次领取，该账户的领取结果通过 probe 返回，无需再次领取。
+func detectRedPacket(ctx context.Context, acc *account, thread forumThread) (redPacket bool, probe *RedPacketResult, err error) {
+	for {
+		if redPacket, known := stateStore.ThreadRedPacket(thread.TID); known {
+			return redPacket, nil, nil
+		}
+		if thread.Marked {
+			stateStore.SetThreadRedPacket(thread.TID, true)
+			return true, nil, nil
+		}
+		if redPacketMarkersOnly {
+			return false, nil, nil
+		}
+
+		redPacketProbes.mu.Lock()
+		done, probing := redPacketProbes.pending[thread.TID]
+		if !probing {
+			done = make(chan struct{})
+			redPacketProbes.pending[thread.TID] = done
+		}
+		redPacketProbes.mu.Unlock()
+
+		if probing {
+			// 等待其他账户的探测结果，探测失败时重新判断
+			select {
+			case <-ctx.Done():
+				return false, nil, ctx.Err()
+			case <-done:
+			}
+			continue
+		}
+
+		result, err := retryTask(ctx, acc, func(ctx context.Context, acc *account) (RedPacketResult, error) {
+			return grabRedPacket(ctx, acc, thread.TID)
+		})
+		if err == nil {
+			stateStore.SetThreadRedPacket(thread.TID, result.Status.claimable())
+		}
+
+		redPacketProbes.mu.Lock()
+		delete(redPacketProbes.pending, thread.TID)
+		redPacketProbes.mu.Unlock()
+		close(done)
+
+		if err != nil {
+			return false, nil, err
+		}
+		return result.Status != RedPacketNone, &result, nil
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckInStatus 定义签到结果状态
+type CheckInStatus string
+
+const (
+	CheckInSuccess CheckInStatus = "success" // 签到成功
+	CheckInAlready CheckInStatus = "already" // 今日已签到
+)
+
+// CheckInResult 定义签到结果，签到失败时通过 error 返回
+type CheckInResult struct {
+	Status     CheckInStatus `json:"status"`
+	Rank       int           `json:"rank"`        // 签到排名，论坛未返回时为 0
+	Coins      int           `json:"coins"`       // 获得的天使币，包含额外奖励
+	ExtraCoins int           `json:"extra_coins"` // 额外奖励的天使币
+}
+
+// String 返回签到结果的描述
+func (r CheckInResult) String() string {
+	if r.Status == CheckInAlready {
+		return "您今天已经签到"
+	}
+
+	message := "签到成功"
+	if r.Rank > 0 {
+		message += fmt.Sprintf("，您是今天第 %d 个签到的会员", r.Rank)
+	}
+	message += fmt.Sprintf("，获得天使币 %d", r.Coins)
+	if r.ExtraCoins > 0 {
+		message += fmt.Sprintf(" (包含额外奖励 %d)", r.ExtraCoins)
+	}
+	return message
+}
+
+// WorkStatus 定义打工结果状态
+type WorkStatus string
+
+const (
+	WorkSuccess WorkStatus = "success" // 打工成功
+	WorkWaiting WorkStatus = "waiting" // 尚未到打工时间
+)
+
+// WorkResult 定义打工结果，打工失败时通过 error 返回
+type WorkResult struct {
+	Status WorkStatus    `json:"status"`
+	Coins  int           `json:"coins"` // 打工获得的天使币
+	Wait   time.Duration `json:"wait"`  // 距离下次可以打工的时间
+}
+
+// String 返回打工结果的描述
+func (r WorkResult) String() string {
+	if r.Status == WorkWaiting {
+		return fmt.Sprintf("还需等待 %s 才能打工", r.Wait)
+	}
+	return fmt.Sprintf("打工成功，获得天使币 %d", r.Coins)
+}
+
+// RedPacketStatus 定义红包结果状态
+type RedPacketStatus string
+
+const (
+	RedPacketGrabbed RedPacketStatus = "grabbed" // 抢到红包
+	RedPacketLate    RedPacketStatus = "late"    // 红包已被抢光
+	RedPacketAlready RedPacketStatus = "already" // 已领取过该红包
+	RedPacketNone    RedPacketStatus = "none"    // 帖子没有红包
+)
+
+// claimable 返回其他账户是否还能领取该红包，没有红包或红包已被抢光时返回 false
+func (s RedPacketStatus) claimable() bool {
+	return s != RedPacketNone && s != RedPacketLate
+}
+
+// RedPacketResult 定义抢红包结果，请求失败或无法识别时通过 error 返回
+type RedPacketResult struct {
+	Status RedPacketStatus `json:"status"`
+	TID    string          `json:"tid"`
+	Coins  int             `json:"coins"` // 红包中的天使币
+}
+
+// String 返回抢红包结果的描述
+func (r RedPacketResult) String() string {
+	switch r.Status {
+	case RedPacketGrabbed:
+		return fmt.Sprintf("抢到红包啦！获得 %d 天使币", r.Coins)
+	case RedPacketLate:
+		return "来晚了，红包已被抢光"
+	case RedPacketAlready:
+		return "您已领取过此红包"
+	default:
+		return "这个主题并没有红包"
+	}
+}
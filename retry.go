@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 定义任务失败后的重试策略，等待时间从 base_delay 开始每次翻倍，不超过 max_delay
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 最多执行的次数，包括第一次执行
+	BaseDelay   time.Duration `yaml:"base_delay"`   // 第一次重试前的等待时间
+	MaxDelay    time.Duration `yaml:"max_delay"`    // 重试前的最长等待时间
+	Jitter      *float64      `yaml:"jitter"`       // 等待时间的随机浮动比例，0-1，避免多个账户同时重试，为 0 时不浮动
+}
+
+// defaultJitter 定义默认的等待时间随机浮动比例
+var defaultJitter = 0.2
+
+// defaultRetryPolicy 定义任务请求失败后的默认重试策略
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Jitter:      &defaultJitter,
+}
+
+// defaultCheckInRetryPolicy 定义守护进程中零点签到失败后的默认重试策略，持续重试直到当天签到成功
+var defaultCheckInRetryPolicy = RetryPolicy{
+	MaxAttempts: 100,
+	BaseDelay:   time.Minute,
+	MaxDelay:    15 * time.Minute,
+	Jitter:      &defaultJitter,
+}
+
+// workFailureBackoff 定义守护进程中打工失败后再次打工前的等待时间，连续失败时逐渐延长
+var workFailureBackoff = RetryPolicy{
+	BaseDelay: time.Minute,
+	MaxDelay:  time.Hour,
+	Jitter:    &defaultJitter,
+}
+
+// retryPolicy 定义任务请求使用的重试策略
+var retryPolicy = defaultRetryPolicy
+
+// checkInRetryPolicy 定义守护进程中零点签到失败后使用的重试策略
+var checkInRetryPolicy = defaultCheckInRetryPolicy
+
+// setupRetry 根据配置初始化重试策略，未设置的字段使用默认值
+func setupRetry(config *Config) {
+	retryPolicy = config.Retry.withDefaults(defaultRetryPolicy)
+	checkInRetryPolicy = config.CheckInRetry.withDefaults(defaultCheckInRetryPolicy)
+}
+
+// withDefaults 返回使用 defaults 填充未设置字段后的重试策略
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaults.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaults.MaxDelay
+	}
+	if p.Jitter == nil {
+		p.Jitter = defaults.Jitter
+	}
+	return p
+}
+
+// check 检查重试策略的配置是否有效
+func (p RetryPolicy) check() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts 不能为负数")
+	}
+	if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+		return fmt.Errorf("jitter 应为 0-1")
+	}
+	if p.BaseDelay > 0 && p.MaxDelay > 0 && p.BaseDelay > p.MaxDelay {
+		return fmt.Errorf("base_delay 不能大于 max_delay")
+	}
+	return nil
+}
+
+// delay 返回第 attempt 次执行失败后的等待时间，attempt 从 1 开始
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	if p.Jitter != nil && *p.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + *p.Jitter*(2*rand.Float64()-1)))
+	}
+	return delay
+}
+
+// temporaryError 表示可以重试的临时错误，如网络错误、服务器错误或论坛繁忙
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string { return e.err.Error() }
+func (e *temporaryError) Unwrap() error { return e.err }
+
+// temporary 将错误标记为可以重试的临时错误
+func temporary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &temporaryError{err: err}
+}
+
+// busyMarkers 定义论坛繁忙或请求过于频繁时提示信息中出现的内容
+var busyMarkers = []string{
+	"请稍后再试",
+	"刷新过于频繁",
+	"服务器繁忙",
+}
+
+// forumError 根据论坛返回的提示信息创建错误，论坛繁忙时返回可以重试的临时错误
+func forumError(prefix, message string) error {
+	err := fmt.Errorf("%s: %s", prefix, message)
+	for _, marker := range busyMarkers {
+		if strings.Contains(message, marker) {
+			return temporary(err)
+		}
+	}
+	return err
+}
+
+// isRetryable 返回错误是否为可以重试的临时错误，未登录、context 取消以及论坛明确拒绝的错误不重试
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrNotLoggedIn) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var temporaryErr *temporaryError
+	return errors.As(err, &temporaryErr)
+}
+
+// retry 按重试策略执行任务，retryable 返回 false 的错误直接返回，等待重试期间 ctx 取消时返回 ctx 的错误
+func retry[T any](ctx context.Context, policy RetryPolicy, retryable func(error) bool, logger *slog.Logger, task func(ctx context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := task(ctx)
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return result, err
+		}
+
+		delay := policy.delay(attempt)
+		logger.Warn("执行失败，等待后重试", "attempt", attempt, "max_attempts", policy.MaxAttempts, "delay", delay.Round(time.Millisecond), "error", err)
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryTask 按任务请求的重试策略执行任务，遇到临时错误时等待后重试，cookie 失效时重新登录后再执行
+func retryTask[T any](ctx context.Context, acc *account, task func(ctx context.Context, acc *account) (T, error)) (T, error) {
+	return retry(ctx, retryPolicy, isRetryable, acc.logger("retry"), func(ctx context.Context) (T, error) {
+		return retryAfterLogin(ctx, acc, task)
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// jitter 返回指向 value 的指针，用于配置等待时间的随机浮动比例
+func jitter(value float64) *float64 {
+	return &value
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: jitter(0)}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{100, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.attempt); got != tt.want {
+			t.Errorf("delay(%d) = %v，应为 %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	tests := []struct {
+		jitter   *float64
+		min, max time.Duration
+	}{
+		{nil, 4 * time.Second, 4 * time.Second},
+		{jitter(0), 4 * time.Second, 4 * time.Second},
+		{jitter(0.5), 2 * time.Second, 6 * time.Second},
+		{jitter(1), 0, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: tt.jitter}
+		for range 100 {
+			if got := policy.delay(3); got < tt.min || got > tt.max {
+				t.Errorf("jitter 为 %v 时 delay(3) = %v，应在 %v 与 %v 之间", tt.jitter, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryPolicyWithDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   RetryPolicy
+	}{
+		{"未设置", RetryPolicy{}, defaultRetryPolicy},
+		{"jitter 为 0", RetryPolicy{Jitter: jitter(0)}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Jitter: jitter(0)}},
+		{"自定义", RetryPolicy{MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: time.Minute, Jitter: jitter(0.5)}, RetryPolicy{MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: time.Minute, Jitter: jitter(0.5)}},
+	}
+	for _, tt := range tests {
+		got := tt.policy.withDefaults(defaultRetryPolicy)
+		if got.MaxAttempts != tt.want.MaxAttempts || got.BaseDelay != tt.want.BaseDelay || got.MaxDelay != tt.want.MaxDelay || *got.Jitter != *tt.want.Jitter {
+			t.Errorf("%s: withDefaults() = %+v (jitter %v)，应为 %+v (jitter %v)", tt.name, got, *got.Jitter, tt.want, *tt.want.Jitter)
+		}
+	}
+}
+
+func TestRetryPolicyCheck(t *testing.T) {
+	tests := []struct {
+		policy RetryPolicy
+		valid  bool
+	}{
+		{RetryPolicy{}, true},
+		{RetryPolicy{Jitter: jitter(0)}, true},
+		{RetryPolicy{Jitter: jitter(1.5)}, false},
+		{RetryPolicy{Jitter: jitter(-0.1)}, false},
+		{RetryPolicy{MaxAttempts: -1}, false},
+		{RetryPolicy{BaseDelay: time.Minute, MaxDelay: time.Second}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.check(); (err == nil) != tt.valid {
+			t.Errorf("check(%+v) = %v", tt.policy, err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Jitter: jitter(0)}
+	errBusy := temporary(errors.New("论坛繁忙"))
+	errDenied := errors.New("论坛拒绝")
+	tests := []struct {
+		name      string
+		errs      []error // 每次执行返回的错误，用完后返回 nil
+		wantCalls int
+		wantErr   error
+	}{
+		{"第一次成功", nil, 1, nil},
+		{"重试后成功", []error{errBusy, errBusy}, 3, nil},
+		{"重试次数用尽", []error{errBusy, errBusy, errBusy, errBusy}, 3, errBusy},
+		{"不可重试的错误", []error{errDenied}, 1, errDenied},
+		{"未登录", []error{ErrNotLoggedIn}, 1, ErrNotLoggedIn},
+	}
+	for _, tt := range tests {
+		calls := 0
+		_, err := retry(context.Background(), policy, isRetryable, slog.Default(), func(ctx context.Context) (int, error) {
+			calls++
+			if calls <= len(tt.errs) {
+				return 0, tt.errs[calls-1]
+			}
+			return calls, nil
+		})
+		if calls != tt.wantCalls || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: 执行 %d 次，错误为 %v", tt.name, calls, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpproxy"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/time/rate"
+)
+
+// proxyDirect 定义账户不使用代理的 proxy 配置值，用于覆盖全局代理
+const proxyDirect = "direct"
+
+// forumSession 定义账户访问论坛使用的 HTTP 客户端、cookie 与请求头，每个账户独立，连接池与代理互不影响
+type forumSession struct {
+	client  *fasthttp.Client
+	jar     *cookieJar
+	headers map[string]string // 请求头配置生成的请求头，附加在每个论坛请求上
+	timeout time.Duration     // 单个请求的超时时间
+
+	limiter        *rate.Limiter // 账户的请求限速器
+	checkInLimiter *rate.Limiter // 零点签到期间使用的请求限速器
+}
+
+// TimeoutConfig 定义论坛请求的超时时间
+type TimeoutConfig struct {
+	Request time.Duration `yaml:"request"` // 单个请求从发送到读取完响应的超时时间，默认为 30s
+	Dial    time.Duration `yaml:"dial"`    // 建立连接 (包括连接代理) 的超时时间，默认为 10s
+}
+
+// withDefaults 返回填充默认值后的超时配置
+func (c TimeoutConfig) withDefaults() TimeoutConfig {
+	if c.Request <= 0 {
+		c.Request = 30 * time.Second
+	}
+	if c.Dial <= 0 {
+		c.Dial = 10 * time.Second
+	}
+	return c
+}
+
+// HeaderProfile 定义请求头配置，模拟浏览器发送的请求头
+type HeaderProfile struct {
+	UserAgent      string            `yaml:"user_agent"`
+	AcceptLanguage string            `yaml:"accept_language"`
+	Headers        map[string]string `yaml:"headers"` // 额外的请求头
+}
+
+// defaultHeaderProfile 定义默认的请求头配置，自定义的配置中未设置的字段使用该值
+var defaultHeaderProfile = HeaderProfile{
+	UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
+	AcceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8",
+	Headers: map[string]string{
+		"Accept": "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+	},
+}
+
+// resolveHeaderProfile 根据名称查找请求头配置并生成请求头，name 为空时使用默认配置
+func resolveHeaderProfile(profiles map[string]HeaderProfile, name string) (map[string]string, error) {
+	profile := defaultHeaderProfile
+	headers := maps.Clone(defaultHeaderProfile.Headers)
+	if name != "" {
+		custom, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("请求头配置 %q 不存在", name)
+		}
+		if custom.UserAgent != "" {
+			profile.UserAgent = custom.UserAgent
+		}
+		if custom.AcceptLanguage != "" {
+			profile.AcceptLanguage = custom.AcceptLanguage
+		}
+		maps.Copy(headers, custom.Headers)
+	}
+
+	headers["User-Agent"] = profile.UserAgent
+	headers["Accept-Language"] = profile.AcceptLanguage
+	return headers, nil
+}
+
+// newHTTPClient 创建访问论坛的 HTTP 客户端，proxy 不为空时通过 HTTP 或 SOCKS5 代理连接
+func newHTTPClient(proxy string, dialTimeout time.Duration) (*fasthttp.Client, error) {
+	client := &fasthttp.Client{
+		MaxConnsPerHost:     200,
+		MaxIdleConnDuration: 30 * time.Second,
+		MaxConnDuration:     5 * time.Minute,
+	}
+	if proxy == "" || proxy == proxyDirect {
+		client.Dial = func(addr string) (net.Conn, error) {
+			return fasthttp.DialTimeout(addr, dialTimeout)
+		}
+		return client, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址 %q 无效: %w", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("代理地址 %q 无效: 只支持 http、socks5 与 socks5h", proxy)
+	}
+
+	dialer := &fasthttpproxy.Dialer{
+		Config:         httpproxy.Config{HTTPProxy: proxy, HTTPSProxy: proxy},
+		Timeout:        dialTimeout,
+		ConnectTimeout: dialTimeout,
+	}
+	dial, err := dialer.GetDialFunc(false)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址 %q 无效: %w", proxy, err)
+	}
+	client.Dial = dial
+	return client, nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// stateFileName 定义状态文件名
+	stateFileName = "state.json"
+	// formhashTTL 定义 formhash 的有效期
+	formhashTTL = 30 * 24 * time.Hour
+	// threadRefreshAge 定义帖子记录超过该时间后仍在列表中时刷新记录时间
+	threadRefreshAge = 3 * 24 * time.Hour
+	// threadTTL 定义帖子记录的保留时间
+	threadTTL = 7 * 24 * time.Hour
+)
+
+// forumLocation 定义论坛所在时区 (UTC+8)
+var forumLocation = time.FixedZone("UTC+8", 8*60*60)
+
+// forumDate 返回指定时间在论坛时区的日期
+func forumDate(t time.Time) string {
+	return t.In(forumLocation).Format(time.DateOnly)
+}
+
+// AccountState 定义需要持久化的账户状态
+type AccountState struct {
+	Formhash     string                       `json:"formhash,omitempty"`
+	FormhashTime time.Time                    `json:"formhash_time,omitempty"`
+	SeenThreads  map[string]time.Time         `json:"seen_threads,omitempty"`  // 已检查过红包的帖子，key 为 tid，value 为记录时间
+	LastCheckIn  string                       `json:"last_check_in,omitempty"` // 最后一次签到的日期 (UTC+8)
+	NextWork     time.Time                    `json:"next_work,omitempty"`     // 下次可以打工的时间
+	Cookies      map[string]map[string]string `json:"cookies,omitempty"`       // 每个镜像主机的 cookie，包含论坛通过 Set-Cookie 更新的值
+	CookieSeed   string                       `json:"cookie_seed,omitempty"`   // 生成 cookie 集合时配置的 cookie 的摘要，配置变化后不再使用保存的 cookie
+}
+
+// ThreadState 定义帖子的红包检测结果，所有账户共享
+type ThreadState struct {
+	RedPacket bool      `json:"red_packet"` // 帖子是否有红包
+	Time      time.Time `json:"time"`       // 检测时间
+}
+
+// stateData 定义状态文件的内容
+type stateData struct {
+	Accounts map[string]*AccountState `json:"accounts"`          // key 为账户名称
+	Threads  map[string]ThreadState   `json:"threads,omitempty"` // 帖子的红包检测结果，key 为 tid
+}
+
+// StateStore 定义保存在本地 JSON 文件中的状态存储
+type StateStore struct {
+	mu          sync.Mutex
+	path        string
+	data        stateData
+	skipCookies bool // 不在状态文件中保存 cookie 集合，状态文件可能被他人读取时使用
+
+	claiming map[threadClaim]bool // 正在处理的帖子，不写入状态文件
+}
+
+// threadClaim 定义账户正在处理的帖子
+type threadClaim struct {
+	name string
+	tid  string
+}
+
+// stateStore 定义全局状态存储，未调用 openStateStore 时只保存在内存中
+var stateStore = &StateStore{data: stateData{Accounts: map[string]*AccountState{}}}
+
+// openStateStore 打开指定目录下的状态文件，文件不存在时创建空的状态存储
+func openStateStore(dir string) (*StateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建状态目录失败: %w", err)
+	}
+
+	store := &StateStore{
+		path: filepath.Join(dir, stateFileName),
+		data: stateData{Accounts: map[string]*AccountState{}},
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.data); err != nil {
+		return nil, fmt.Errorf("解析状态文件失败: %w", err)
+	}
+	if store.data.Accounts == nil {
+		store.data.Accounts = map[string]*AccountState{}
+	}
+	return store, nil
+}
+
+// account 返回账户的状态，不存在时创建，调用方需持有锁
+func (s *StateStore) account(name string) *AccountState {
+	state, ok := s.data.Accounts[name]
+	if !ok {
+		state = &AccountState{}
+		s.data.Accounts[name] = state
+	}
+	if state.SeenThreads == nil {
+		state.SeenThreads = map[string]time.Time{}
+	}
+	return state
+}
+
+// Formhash 返回账户未过期的 formhash
+func (s *StateStore) Formhash(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.account(name)
+	if state.Formhash == "" || time.Since(state.FormhashTime) > formhashTTL {
+		return "", false
+	}
+	return state.Formhash, true
+}
+
+// SetFormhash 保存账户的 formhash，formhash 为空时删除
+func (s *StateStore) SetFormhash(name, formhash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.account(name)
+	state.Formhash = formhash
+	state.FormhashTime = time.Now()
+	if formhash == "" {
+		state.FormhashTime = time.Time{}
+	}
+	s.save()
+}
+
+// ClaimThread 检查帖子是否需要处理，返回 true 时记录该账户正在处理该帖子，其他任务不会同时处理。
+// 已处理过或正在处理的帖子返回 false；返回 true 时处理完成后需调用 ReleaseThread，处理成功时先调用 MarkThread。
+func (s *StateStore) ClaimThread(name, tid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claim := threadClaim{name: name, tid: tid}
+	if s.claiming[claim] {
+		return false
+	}
+
+	state := s.account(name)
+	if seen, ok := state.SeenThreads[tid]; ok && time.Since(seen) <= threadTTL {
+		// 帖子长时间留在列表中，刷新记录时间，避免过期后重复处理
+		if time.Since(seen) > threadRefreshAge {
+			state.SeenThreads[tid] = time.Now()
+		}
+		return false
+	}
+
+	if s.claiming == nil {
+		s.claiming = map[threadClaim]bool{}
+	}
+	s.claiming[claim] = true
+	return true
+}
+
+// ReleaseThread 结束账户对帖子的处理，与 ClaimThread 成对调用
+func (s *StateStore) ReleaseThread(name, tid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.claiming, threadClaim{name: name, tid: tid})
+}
+
+// MarkThread 记录帖子已处理，需调用 Flush 写入文件
+func (s *StateStore) MarkThread(name, tid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.account(name).SeenThreads[tid] = time.Now()
+}
+
+// ThreadRedPacket 返回帖子的红包检测结果，未检测过或记录已过期时 known 为 false
+func (s *StateStore) ThreadRedPacket(tid string) (redPacket, known bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thread, ok := s.data.Threads[tid]
+	if !ok || time.Since(thread.Time) > threadTTL {
+		return false, false
+	}
+	return thread.RedPacket, true
+}
+
+// SetThreadRedPacket 记录帖子的红包检测结果，需调用 Flush 写入文件
+func (s *StateStore) SetThreadRedPacket(tid string, redPacket bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Threads == nil {
+		s.data.Threads = map[string]ThreadState{}
+	}
+	s.data.Threads[tid] = ThreadState{RedPacket: redPacket, Time: time.Now()}
+}
+
+// LastCheckIn 返回账户最后一次签到的日期 (UTC+8)
+func (s *StateStore) LastCheckIn(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.account(name).LastCheckIn
+}
+
+// SetLastCheckIn 记录账户的签到日期
+func (s *StateStore) SetLastCheckIn(name string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.account(name).LastCheckIn = forumDate(t)
+	s.save()
+}
+
+// NextWork 返回账户下次可以打工的时间
+func (s *StateStore) NextWork(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.account(name).NextWork
+}
+
+// SetNextWork 记录账户下次可以打工的时间
+func (s *StateStore) SetNextWork(name string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.account(name).NextWork = t
+	s.save()
+}
+
+// Cookies 返回账户保存的每个镜像主机的 cookie，seed 为配置的 cookie 字符串，与保存时的配置不一致时返回 false
+func (s *StateStore) Cookies(name, seed string) (map[string]map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.account(name)
+	if s.skipCookies || len(state.Cookies) == 0 || state.CookieSeed != cookieSeed(seed) {
+		return nil, false
+	}
+	return cloneHostCookies(state.Cookies), true
+}
+
+// SetCookies 记录账户的每个镜像主机的 cookie，需调用 Flush 写入文件
+func (s *StateStore) SetCookies(name, seed string, cookies map[string]map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.skipCookies {
+		return
+	}
+	state := s.account(name)
+	state.Cookies = cookies
+	state.CookieSeed = cookieSeed(seed)
+}
+
+// cookieSeed 返回配置的 cookie 字符串的摘要，避免在状态文件中重复保存
+func cookieSeed(cookie string) string {
+	sum := sha256.Sum256([]byte(cookie))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Flush 将状态写入文件
+func (s *StateStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.save()
+}
+
+// save 清理过期的帖子记录与检测结果并将状态写入文件，调用方需持有锁
+func (s *StateStore) save() {
+	if s.path == "" {
+		return
+	}
+
+	for _, state := range s.data.Accounts {
+		if s.skipCookies {
+			// 同时删除之前保存的 cookie
+			state.Cookies, state.CookieSeed = nil, ""
+		}
+		for tid, seen := range state.SeenThreads {
+			if time.Since(seen) > threadTTL {
+				delete(state.SeenThreads, tid)
+			}
+		}
+	}
+	for tid, thread := range s.data.Threads {
+		if time.Since(thread.Time) > threadTTL {
+			delete(s.data.Threads, tid)
+		}
+	}
+
+	if err := s.writeFile(); err != nil {
+		slog.Error("保存状态文件失败", "path", s.path, "error", err)
+	}
+}
+
+// writeFile 先写入临时文件再重命名，避免程序中断时损坏状态文件
+func (s *StateStore) writeFile() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// maxRedPacketRecords 定义状态页保留的红包记录数
+	maxRedPacketRecords = 50
+	// maxErrorRecords 定义状态页保留的错误记录数
+	maxErrorRecords = 20
+)
+
+// CheckInRecord 定义签到记录
+type CheckInRecord struct {
+	Time   time.Time      `json:"time"`
+	Result *CheckInResult `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// RedPacketRecord 定义抢到的红包记录
+type RedPacketRecord struct {
+	Time time.Time `json:"time"`
+	RedPacketResult
+}
+
+// ErrorRecord 定义任务出错记录
+type ErrorRecord struct {
+	Time    time.Time `json:"time"`
+	Task    string    `json:"task"`
+	Message string    `json:"message"`
+}
+
+// AccountStatus 定义账户任务状态，用于状态页展示
+type AccountStatus struct {
+	Name        string            `json:"name"`
+	LoggedOut   bool              `json:"logged_out"` // cookie 已失效，已停止该账户的任务
+	LastCheckIn *CheckInRecord    `json:"last_check_in,omitempty"`
+	LastWork    time.Time         `json:"last_work"`
+	NextWork    time.Time         `json:"next_work"`
+	Score       string            `json:"score,omitempty"` // 最近一次获取到的天使币数量
+	ScoreTime   time.Time         `json:"score_time"`
+	RedPackets  []RedPacketRecord `json:"red_packets"` // 最近抢到的红包，按时间倒序
+	Errors      []ErrorRecord     `json:"errors"`      // 最近的错误，按时间倒序
+}
+
+// accountStatus 记录账户任务状态
+type accountStatus struct {
+	mu     sync.Mutex
+	status AccountStatus
+}
+
+// recordCheckIn 记录签到结果
+func (s *accountStatus) recordCheckIn(result CheckInResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastCheckIn = &CheckInRecord{Time: time.Now(), Result: &result}
+}
+
+// recordCheckInError 记录签到失败
+func (s *accountStatus) recordCheckInError(err error) {
+	s.mu.Lock()
+	s.status.LastCheckIn = &CheckInRecord{Time: time.Now(), Error: err.Error()}
+	s.mu.Unlock()
+
+	s.recordError("签到", err)
+}
+
+// recordWork 记录打工成功
+func (s *accountStatus) recordWork() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastWork = time.Now()
+}
+
+// recordScore 记录天使币数量
+func (s *accountStatus) recordScore(score string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Score = score
+	s.status.ScoreTime = time.Now()
+}
+
+// recordRedPacket 记录抢到的红包
+func (s *accountStatus) recordRedPacket(result RedPacketResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := RedPacketRecord{Time: time.Now(), RedPacketResult: result}
+	s.status.RedPackets = append([]RedPacketRecord{record}, s.status.RedPackets...)
+	if len(s.status.RedPackets) > maxRedPacketRecords {
+		s.status.RedPackets = s.status.RedPackets[:maxRedPacketRecords]
+	}
+}
+
+// recordError 记录任务出错
+func (s *accountStatus) recordError(task string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := ErrorRecord{Time: time.Now(), Task: task, Message: err.Error()}
+	s.status.Errors = append([]ErrorRecord{record}, s.status.Errors...)
+	if len(s.status.Errors) > maxErrorRecords {
+		s.status.Errors = s.status.Errors[:maxErrorRecords]
+	}
+}
+
+// snapshot 返回账户任务状态的副本
+func (s *accountStatus) snapshot() AccountStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.RedPackets = append([]RedPacketRecord{}, s.status.RedPackets...)
+	status.Errors = append([]ErrorRecord{}, s.status.Errors...)
+	return status
+}
+
+// statusServer 定义状态页 HTTP 服务
+type statusServer struct {
+	accounts []*account
+	started  time.Time
+	mux      *http.ServeMux
+}
+
+// newStatusServer 创建状态页 HTTP 服务
+func newStatusServer(accounts []*account) *statusServer {
+	s := &statusServer{
+		accounts: accounts,
+		started:  time.Now(),
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /{$}", s.handleHTML)
+	s.mux.HandleFunc("GET /api/status", s.handleJSON)
+	s.mux.Handle("GET /metrics", promhttp.Handler())
+	return s
+}
+
+// serve 在指定地址上运行 HTTP 服务，ctx 取消时关闭服务
+func (s *statusServer) serve(ctx context.Context, listen string) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("状态页已启动", "listen", listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("状态页服务出错: %w", err)
+	}
+	return nil
+}
+
+// statusResponse 定义状态接口的返回内容
+type statusResponse struct {
+	Started  time.Time       `json:"started"`
+	Clock    *ClockStatus    `json:"clock,omitempty"` // 论坛服务器时间偏差，尚未请求论坛时为空
+	Accounts []AccountStatus `json:"accounts"`
+}
+
+// collect 汇总所有账户的任务状态
+func (s *statusServer) collect() statusResponse {
+	resp := statusResponse{Started: s.started, Clock: forumClock.status()}
+	for _, acc := range s.accounts {
+		status := acc.status.snapshot()
+		status.Name = acc.Name
+		status.LoggedOut = !acc.active()
+		status.NextWork = stateStore.NextWork(acc.Name)
+		resp.Accounts = append(resp.Accounts, status)
+	}
+	return resp
+}
+
+// handleJSON 以 JSON 格式返回任务状态
+func (s *statusServer) handleJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(s.collect())
+}
+
+// handleHTML 以 HTML 页面展示任务状态
+func (s *statusServer) handleHTML(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, s.collect()); err != nil {
+		slog.Error("渲染状态页失败", "error", err)
+	}
+}
+
+// statusTemplate 定义状态页模板
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.In(forumLocation).Format(time.DateTime)
+	},
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"offset": func(d time.Duration) string {
+		if d < 0 {
+			return d.Round(time.Millisecond).String()
+		}
+		return "+" + d.Round(time.Millisecond).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>天使动漫论坛任务状态</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>天使动漫论坛任务状态</h1>
+<p>启动时间: {{time .Started}}，服务器时间偏差: {{with .Clock}}{{offset .Offset}} (±{{duration .Uncertainty}}){{else}}-{{end}}，<a href="/api/status">JSON</a></p>
+{{range .Accounts}}
+<h2>{{.Name}}</h2>
+<table>
+{{if .LoggedOut}}<tr><th>登录状态</th><td class="error">cookie 已失效，已停止该账户的任务</td></tr>
+{{end}}<tr><th>最近签到</th><td>{{with .LastCheckIn}}{{time .Time}} {{if .Result}}{{.Result}}{{else}}<span class="error">{{.Error}}</span>{{end}}{{else}}-{{end}}</td></tr>
+<tr><th>最近打工</th><td>{{time .LastWork}}</td></tr>
+<tr><th>下次打工</th><td>{{time .NextWork}}</td></tr>
+<tr><th>天使币</th><td>{{if .Score}}{{.Score}} ({{time .ScoreTime}}){{else}}-{{end}}</td></tr>
+</table>
+<h3>红包</h3>
+{{if .RedPackets}}<table>
+<tr><th>时间</th><th>帖子</th><th>天使币</th></tr>
+{{range .RedPackets}}<tr><td>{{time .Time}}</td><td>{{.TID}}</td><td>{{.Coins}}</td></tr>
+{{end}}</table>{{else}}<p>暂无</p>{{end}}
+<h3>最近错误</h3>
+{{if .Errors}}<table>
+<tr><th>时间</th><th>任务</th><th>信息</th></tr>
+{{range .Errors}}<tr><td>{{time .Time}}</td><td>{{.Task}}</td><td class="error">{{.Message}}</td></tr>
+{{end}}</table>{{else}}<p>暂无</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedPacketWatchConfig 定义守护进程中检查新帖子的配置，发现新帖子后立即为所有账户抢红包
+type RedPacketWatchConfig struct {
+	Interval    time.Duration `yaml:"interval"`     // 检查最新帖子的间隔，默认为 30s，为负数时不检查
+	MaxInterval time.Duration `yaml:"max_interval"` // 没有新帖子时逐渐延长检查间隔的上限，安静时段使用该间隔，默认为 5m
+	QuietHours  string        `yaml:"quiet_hours"`  // 安静时段 (论坛时间)，如 02:00-08:00，为空时不设置
+}
+
+// defaultRedPacketWatch 定义默认的新帖子检查配置
+var defaultRedPacketWatch = RedPacketWatchConfig{
+	Interval:    30 * time.Second,
+	MaxInterval: 5 * time.Minute,
+}
+
+// watchBackoff 定义每次没有发现新帖子时检查间隔延长的倍数
+const watchBackoff = 1.5
+
+// withDefaults 返回填充默认值后的新帖子检查配置
+func (c RedPacketWatchConfig) withDefaults() RedPacketWatchConfig {
+	if c.Interval == 0 {
+		c.Interval = defaultRedPacketWatch.Interval
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = max(defaultRedPacketWatch.MaxInterval, c.Interval)
+	}
+	return c
+}
+
+// check 检查新帖子检查配置是否有效
+func (c RedPacketWatchConfig) check() error {
+	if c.Interval > 0 && c.MaxInterval > 0 && c.Interval > c.MaxInterval {
+		return fmt.Errorf("watch.interval 不能大于 watch.max_interval")
+	}
+	if _, _, err := parseQuietHours(c.QuietHours); err != nil {
+		return fmt.Errorf("watch.quiet_hours 无效: %w", err)
+	}
+	return nil
+}
+
+// parseQuietHours 解析 HH:MM-HH:MM 格式的安静时段，返回开始与结束时间距离零点的时长，为空时返回两个 0
+func parseQuietHours(quietHours string) (start, end time.Duration, err error) {
+	if quietHours == "" {
+		return 0, 0, nil
+	}
+
+	startText, endText, ok := strings.Cut(quietHours, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("格式应为 HH:MM-HH:MM")
+	}
+	if start, err = parseClock(startText); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(endText); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回距离零点的时长
+func parseClock(text string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("时间 %q 的格式应为 HH:MM", text)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// inQuietHours 返回论坛时间 t 是否在安静时段内，安静时段可以跨越零点
+func inQuietHours(t time.Time, start, end time.Duration) bool {
+	if start == end {
+		return false
+	}
+	t = t.In(forumLocation)
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// newestThreadsPath 返回版块按发帖时间排序的帖子列表的路径
+func newestThreadsPath(fid int) string {
+	return fmt.Sprintf("/forum.php?mod=forumdisplay&fid=%d&filter=author&orderby=dateline", fid)
+}
+
+// watchRedPackets 按间隔检查配置的版块中按发帖时间排序的最新帖子，记录每个版块见过的最大帖子 ID，
+// 出现更新的帖子时立即为所有账户并行抢红包。没有新帖子时逐渐延长检查间隔，安静时段使用最长间隔。
+func watchRedPackets(ctx context.Context, accounts []*account, watch RedPacketWatchConfig) {
+	quietStart, quietEnd, _ := parseQuietHours(watch.QuietHours)
+	logger := slog.With("task", "watch")
+	logger.Info("开始检查新帖子", "interval", watch.Interval, "max_interval", watch.MaxInterval)
+
+	highWater := make(map[int]int) // 每个版块见过的最大帖子 ID，首次检查成功后记录
+	interval := watch.Interval
+	for {
+		// 使用第一个 cookie 有效的账户获取帖子列表
+		var acc *account
+		for _, candidate := range accounts {
+			if candidate.active() {
+				acc = candidate
+				break
+			}
+		}
+
+		found := false
+		if acc != nil {
+			var threads []forumThread
+			seen := make(map[string]bool) // 同一帖子可能出现在多个版块中
+			for _, forum := range redPacketForums {
+				newThreads, err := pollNewThreads(ctx, acc, forum.FID, highWater)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					logger.Warn("检查新帖子失败", "fid", forum.FID, "error", err)
+					continue
+				}
+				for _, thread := range newThreads {
+					if !seen[thread.TID] {
+						seen[thread.TID] = true
+						threads = append(threads, thread)
+					}
+				}
+			}
+
+			if len(threads) > 0 {
+				found = true
+				logger.Debug("发现新帖子", "count", len(threads))
+				dispatchClaims(ctx, accounts, threads)
+			}
+		}
+
+		// 发现新帖子时恢复最短间隔，否则逐渐延长，安静时段直接使用最长间隔
+		switch {
+		case inQuietHours(forumClock.now(), quietStart, quietEnd):
+			interval = watch.MaxInterval
+		case found:
+			interval = watch.Interval
+		default:
+			interval = min(time.Duration(float64(interval)*watchBackoff), watch.MaxInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollNewThreads 获取版块按发帖时间排序的帖子列表，返回帖子 ID 大于该版块最大帖子 ID 的帖子，并更新最大帖子 ID。
+// 首次检查版块时只记录最大帖子 ID，不返回帖子，启动前发布的帖子由定时的完整检查处理。
+func pollNewThreads(ctx context.Context, acc *account, fid int, highWater map[int]int) ([]forumThread, error) {
+	threads, err := retryTask(ctx, acc, func(ctx context.Context, acc *account) ([]forumThread, error) {
+		return scanForum(ctx, acc, newestThreadsPath(fid))
+	})
+	if err != nil {
+		acc.handleLoginError(err)
+		return nil, err
+	}
+
+	mark, known := highWater[fid]
+	highWater[fid] = mark
+	var newThreads []forumThread
+	for _, thread := range threads {
+		tid, err := strconv.Atoi(thread.TID)
+		if err != nil || tid <= mark {
+			continue
+		}
+		if known {
+			newThreads = append(newThreads, thread)
+		}
+		highWater[fid] = max(highWater[fid], tid)
+	}
+	return newThreads, nil
+}
+
+// dispatchClaims 为所有 cookie 有效的账户并行领取帖子中的红包，等待全部完成后保存状态
+func dispatchClaims(ctx context.Context, accounts []*account, threads []forumThread) {
+	var wg sync.WaitGroup
+	for _, acc := range accounts {
+		if !acc.active() {
+			continue
+		}
+		for _, thread := range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				claimThread(ctx, acc, thread)
+			}()
+		}
+	}
+	wg.Wait()
+	stateStore.Flush()
+}